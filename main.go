@@ -7,40 +7,285 @@ import (
 
 	"flag"
 	"fmt"
+	"log/slog"
 
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/line"
+	"github.com/varas/numserver/pkg/result"
 	"github.com/varas/numserver/pkg/server"
 )
 
 var (
-	port = flag.Int("port", server.DefaultPort, fmt.Sprintf("-port %d", server.DefaultPort))
-	file = flag.String("file", server.DefaultLogFile, fmt.Sprintf("-file %s", server.DefaultLogFile))
-	// we could also add other config params like:
-	// * concurrentClients
-	// * resultFlushInterval
-	// * reportFlushInterval
+	port        = flag.Int("port", server.DefaultPort, fmt.Sprintf("-port %d", server.DefaultPort))
+	listen      = flag.String("listen", "", "-listen 127.0.0.1:4000,tcp6:[::1]:4000,unix:/tmp/numserver.sock listens on these addresses instead of -port")
+	file        = flag.String("file", server.DefaultLogFile, fmt.Sprintf("-file %s", server.DefaultLogFile))
+	httpAddress = flag.String("http", "", "-http :8080 serves POST /numbers on this address")
+	query       = flag.String("query", "", "-query 127.0.0.1:4001 serves read-only membership queries on this address")
+	udp         = flag.String("udp", "", "-udp :4000 reads datagrams of number lines on this udp address")
+	configPath  = flag.String("config", "", "-config numserver.json json file with settings named as flags with underscores, overriding flags and reloaded on SIGHUP")
+	printConfig = flag.Bool("print-config", false, "-print-config prints the effective config as a config file and exits")
+	// intervals and limits
+	reportInterval    = flag.Duration("report-interval", server.DefaultReportFlushInterval, fmt.Sprintf("-report-interval %s report print interval", server.DefaultReportFlushInterval))
+	logFlushInterval  = flag.Duration("log-flush-interval", server.DefaultLogFlushInterval, fmt.Sprintf("-log-flush-interval %s unique numbers log flush interval", server.DefaultLogFlushInterval))
+	logFlushBatchSize = flag.Int("log-flush-batch-size", server.DefaultLogFlushBatchSize, fmt.Sprintf("-log-flush-batch-size %d numbers written to log at once", server.DefaultLogFlushBatchSize))
+	concurrentClients = flag.Int("concurrent-clients", server.DefaultConcurrentClients, fmt.Sprintf("-concurrent-clients %d clients handled at once, shared by tcp and http", server.DefaultConcurrentClients))
+	idleTimeout       = flag.Duration("idle-timeout", 0, "-idle-timeout 5m closes tcp connections waiting for input for this long, 0 disabled")
+	// report file
+	reportFile        = flag.String("report-file", "", "-report-file reports.log appends reports to this file besides standard output")
+	reportFileMaxSize = flag.Int("report-file-max-size", server.DefaultReportFileMaxSize, fmt.Sprintf("-report-file-max-size %d bytes before rotating -report-file, 0 never rotated", server.DefaultReportFileMaxSize))
+	reportFileBackups = flag.Int("report-file-backups", server.DefaultReportFileBackups, fmt.Sprintf("-report-file-backups %d rotated report files kept", server.DefaultReportFileBackups))
+	// line format
+	newline   = flag.String("newline", string(line.DefaultFormat.Newline), "-newline lf|crlf|any line ending accepted")
+	digits    = flag.Int("digits", line.DefaultFormat.Digits, fmt.Sprintf("-digits %d digits per number, leading zeros included", line.DefaultFormat.Digits))
+	minNumber = flag.Uint64("min", 0, "-min 1000 min valid number")
+	maxNumber = flag.Uint64("max", 0, "-max 900000000 max valid number, 0 no limit")
+	// unix socket
+	unixSocket      = flag.String("unix-socket", "", "-unix-socket /tmp/numserver.sock listens also on this unix socket")
+	unixSocketMode  = flag.String("unix-socket-mode", "", "-unix-socket-mode 0660 unix sockets file mode")
+	unixSocketUser  = flag.String("unix-socket-user", "", "-unix-socket-user numserver unix sockets owner user")
+	unixSocketGroup = flag.String("unix-socket-group", "", "-unix-socket-group producers unix sockets owner group")
+	// tls
+	tlsCert     = flag.String("tls-cert", "", "-tls-cert server.crt enables tls with given certificate file")
+	tlsKey      = flag.String("tls-key", "", "-tls-key server.key private key file for -tls-cert")
+	tlsClientCA = flag.String("tls-client-ca", "", "-tls-client-ca ca.crt requires client certificates signed by this CA")
+	// connection access
+	allowCIDRs    = flag.String("allow-cidrs", "", "-allow-cidrs 10.0.0.0/8 accepts connections only from these networks")
+	denyCIDRs     = flag.String("deny-cidrs", "", "-deny-cidrs 10.0.1.0/24 rejects connections from these networks")
+	maxConnsPerIP = flag.Int("max-conns-per-ip", 0, "-max-conns-per-ip 2 limits concurrent connections per client ip, 0 unlimited")
+	// termination authorization
+	terminateDisabled = flag.Bool("terminate-disabled", false, "-terminate-disabled ignores termination lines")
+	terminateCIDRs    = flag.String("terminate-cidrs", "", "-terminate-cidrs 127.0.0.1/32,10.0.0.0/8 allows termination only from these networks")
+	terminateToken    = flag.String("terminate-token", "", "-terminate-token TOKEN requires termination line to be 'terminate TOKEN'")
+	// repository
+	repositoryType = flag.String("repository", server.RepositoryMemory, "-repository memory|bloom|disk|mmap|ttl how unique numbers are stored, disk and mmap keep them on restart, ttl forgets them, all but memory for up to 9 digits")
+	repositoryPath = flag.String("repository-path", "", "-repository-path numbers.bitmap file storing numbers of all but memory repository, -file with .bitmap suffix by default")
+	bloomCapacity  = flag.Int("bloom-capacity", server.DefaultBloomCapacity, fmt.Sprintf("-bloom-capacity %d expected unique numbers for -repository bloom", server.DefaultBloomCapacity))
+	bloomFPRate    = flag.Float64("bloom-fp-rate", server.DefaultBloomFalsePositiveRate, fmt.Sprintf("-bloom-fp-rate %g bloom false positive rate, suspected duplicates are checked on disk", server.DefaultBloomFalsePositiveRate))
+	ttl            = flag.Duration("ttl", server.DefaultTTL, fmt.Sprintf("-ttl %s numbers not seen for this long are unique again with -repository ttl", server.DefaultTTL))
+	ttlBuckets     = flag.Int("ttl-buckets", server.DefaultTTLBuckets, fmt.Sprintf("-ttl-buckets %d rolling window buckets of -ttl, numbers expire a bucket late at most", server.DefaultTTLBuckets))
+	// snapshots
+	snapshotPath     = flag.String("snapshot", "", "-snapshot numbers.snapshot restores numbers from this file on start and saves them on it, up to 9 digits")
+	snapshotInterval = flag.Duration("snapshot-interval", server.DefaultSnapshotInterval, fmt.Sprintf("-snapshot-interval %s snapshot save interval besides on stop, 0 only on stop", server.DefaultSnapshotInterval))
+	// epochs
+	epochInterval = flag.Duration("epoch", 0, "-epoch 24h restarts dedupe on each epoch, aligned to midnight UTC, finalizing the log with the epoch id as suffix, memory repository only")
+	// write-ahead log
+	walDir = flag.String("wal", "", "-wal numbers.wal directory of a write-ahead log recovering accepted numbers not logged yet after a crash")
+	// server logs
+	logLevel  = flag.String("log-level", server.DefaultLoggingLevel, fmt.Sprintf("-log-level %s min level of server logs: debug, info, warn or error", server.DefaultLoggingLevel))
+	logFormat = flag.String("log-format", string(server.DefaultLoggingFormat), fmt.Sprintf("-log-format %s server logs format: text or json", server.DefaultLoggingFormat))
 )
 
 func init() {
 	flag.Parse()
 }
 
+// newConfig creates the config from flags, overridden by config file and then by environment
+func newConfig() (*server.Config, error) {
+	allowNetworks, err := line.ParseNetworks(strings.Split(*allowCIDRs, ","))
+	if err != nil {
+		return nil, fmt.Errorf("invalid -allow-cidrs: %s", err.Error())
+	}
+
+	denyNetworks, err := line.ParseNetworks(strings.Split(*denyCIDRs, ","))
+	if err != nil {
+		return nil, fmt.Errorf("invalid -deny-cidrs: %s", err.Error())
+	}
+
+	terminateNetworks, err := line.ParseNetworks(strings.Split(*terminateCIDRs, ","))
+	if err != nil {
+		return nil, fmt.Errorf("invalid -terminate-cidrs: %s", err.Error())
+	}
+
+	socketMode, err := parseFileMode(*unixSocketMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -unix-socket-mode: %s", err.Error())
+	}
+
+	config := server.NewConfig(*port, *file)
+	if *listen != "" {
+		config.ListenAddresses = strings.Split(*listen, ",")
+	}
+	config.LineFormat = line.Format{
+		Newline: line.Newline(*newline),
+		Digits:  *digits,
+		Min:     *minNumber,
+		Max:     *maxNumber,
+	}
+	config.HTTPAddress = *httpAddress
+	config.QueryAddress = *query
+	config.UDPAddress = *udp
+	config.UnixSocket = server.UnixSocketConfig{
+		Path:  *unixSocket,
+		Mode:  socketMode,
+		User:  *unixSocketUser,
+		Group: *unixSocketGroup,
+	}
+	config.TLS = server.TLSConfig{
+		CertFile:     *tlsCert,
+		KeyFile:      *tlsKey,
+		ClientCAFile: *tlsClientCA,
+	}
+	config.ReportFlushInterval = *reportInterval
+	config.LogFlushInterval = *logFlushInterval
+	config.LogFlushBatchSize = *logFlushBatchSize
+	config.ConcurrentClients = *concurrentClients
+	config.IdleTimeout = *idleTimeout
+	config.ReportFile = server.ReportFileConfig{
+		Path:    *reportFile,
+		MaxSize: *reportFileMaxSize,
+		Backups: *reportFileBackups,
+	}
+	config.Access = server.AccessPolicy{
+		Allow:         allowNetworks,
+		Deny:          denyNetworks,
+		MaxConnsPerIP: *maxConnsPerIP,
+	}
+	config.Termination = line.TerminationPolicy{
+		Disabled: *terminateDisabled,
+		Networks: terminateNetworks,
+		Token:    *terminateToken,
+	}
+
+	config.Repository = server.RepositoryConfig{
+		Type:                   *repositoryType,
+		Path:                   *repositoryPath,
+		BloomCapacity:          *bloomCapacity,
+		BloomFalsePositiveRate: *bloomFPRate,
+		TTL:                    *ttl,
+		TTLBuckets:             *ttlBuckets,
+	}
+
+	config.Snapshot = server.SnapshotConfig{
+		Path:     *snapshotPath,
+		Interval: *snapshotInterval,
+	}
+
+	config.Epochs = result.EpochSchedule{Interval: *epochInterval}
+
+	config.Logging = server.LoggingConfig{
+		Level:  *logLevel,
+		Format: errhandler.Format(*logFormat),
+	}
+
+	config.WALDir = *walDir
+
+	if *configPath != "" {
+		err = server.LoadConfigFile(*configPath, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = server.LoadConfigEnv(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
 func main() {
-	srv := server.NewNumServer(*port, *file)
+	config, err := newConfig()
+	if err != nil {
+		log.Fatalf("[error] %s", err.Error())
+	}
+
+	// printed before validation, as it helps fixing it
+	if *printConfig {
+		err = server.WriteConfig(os.Stdout, *config)
+		if err != nil {
+			log.Fatalf("[error] cannot print config: %s", err.Error())
+		}
+	}
+
+	err = config.Validate()
+	if err != nil {
+		log.Fatalf("[error] invalid config: %s", err.Error())
+	}
+
+	if *printConfig {
+		return
+	}
+
+	logger, err := errhandler.NewLogger(os.Stderr, config.Logging.Format, config.Logging.Level)
+	if err != nil {
+		log.Fatalf("[error] %s", err.Error())
+	}
+
+	srv := server.NewNumServerWithConfig(*config, server.WithLogger(logger))
 
 	// wait for runtime start
 	go func() {
 		<-srv.Ready
-		log.Printf("numserver listening on tcp/%d and writing on %s", *port, *file)
+		logger.Info("numserver listening", "listen", listenDescription(config), "file", config.LogPath)
 	}()
 
 	go srv.Run(context.Background())
 
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go reloadOnSignal(srv, logger, reload)
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	<-c
 	close(srv.Stop)
 }
+
+// reloadOnSignal reloads config from flags and config file on each signal
+func reloadOnSignal(srv *server.NumServer, logger *slog.Logger, signals <-chan os.Signal) {
+	for range signals {
+		config, err := newConfig()
+		if err == nil {
+			err = srv.Reload(*config)
+		}
+		if err != nil {
+			logger.Error("config reload: " + err.Error())
+			continue
+		}
+
+		logger.Info("config reloaded")
+	}
+}
+
+func listenDescription(config *server.Config) string {
+	addresses := config.ListenAddresses
+	if len(addresses) == 0 {
+		addresses = []string{fmt.Sprintf("tcp/%d", config.Port)}
+	}
+
+	if config.UnixSocket.Path != "" {
+		addresses = append(addresses, "unix:"+config.UnixSocket.Path)
+	}
+
+	if config.HTTPAddress != "" {
+		addresses = append(addresses, "http/"+config.HTTPAddress)
+	}
+
+	if config.QueryAddress != "" {
+		addresses = append(addresses, "query/"+config.QueryAddress)
+	}
+
+	if config.UDPAddress != "" {
+		addresses = append(addresses, "udp/"+config.UDPAddress)
+	}
+
+	return strings.Join(addresses, ", ")
+}
+
+// parseFileMode parses an octal file mode like 0660, 0 if empty
+func parseFileMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+
+	m, err := strconv.ParseUint(mode, 8, 32)
+
+	return os.FileMode(m), err
+}
@@ -8,19 +8,16 @@ import (
 // In case errors should be handled in different ways, a proper error type could be more suitable
 type ErrHandler func(error)
 
-// Logger creates a new error handler that uses stdlib log with given prefix
+// Logger creates a new error handler that uses stdlib log output and flags with given prefix,
+// use Structured for leveled logs with fields
 func Logger(preffix string) ErrHandler {
 	return func(err error) {
 		if err == nil {
 			return
 		}
 
-		prev := log.Prefix()
-		log.SetPrefix(preffix)
-
-		log.Println(err)
-
-		log.SetPrefix(prev)
+		// own logger as changing the global prefix races with other loggers
+		log.New(log.Writer(), preffix, log.Flags()).Println(err)
 	}
 }
 
@@ -0,0 +1,82 @@
+package errhandler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Format structured log output format
+type Format string
+
+// Structured log formats
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// NewLogger creates a structured logger writing records of given level or above to w, e.g: level "debug", "info", "warn" or "error"
+func NewLogger(w io.Writer, format Format, level string) (*slog.Logger, error) {
+	var minLevel slog.Level
+	err := minLevel.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+
+	options := &slog.HandlerOptions{Level: minLevel}
+
+	switch format {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// Structured creates an error handler logging errors with given logger at error level, along their fields
+func Structured(logger *slog.Logger) ErrHandler {
+	return func(err error) {
+		if err == nil {
+			return
+		}
+
+		logger.Error(err.Error(), Fields(err)...)
+	}
+}
+
+// fieldsError error annotated with log fields
+type fieldsError struct {
+	err    error
+	fields []interface{}
+}
+
+// WithFields annotates err with key-value pairs logged by Structured handlers, e.g: "conn", 3, "remote", "10.0.0.1:5123"
+// its message is unchanged, so other handlers get the same error
+func WithFields(err error, keyValues ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	return &fieldsError{err: err, fields: keyValues}
+}
+
+func (e *fieldsError) Error() string { return e.err.Error() }
+
+// Cause enables github.com/pkg/errors Cause
+func (e *fieldsError) Cause() error { return e.err }
+
+func (e *fieldsError) Unwrap() error { return e.err }
+
+// Fields returns the key-value pairs err and the errors it wraps are annotated with, the outermost first
+func Fields(err error) (keyValues []interface{}) {
+	for ; err != nil; err = stderrors.Unwrap(err) {
+		if e, ok := err.(*fieldsError); ok {
+			keyValues = append(keyValues, e.fields...)
+		}
+	}
+
+	return keyValues
+}
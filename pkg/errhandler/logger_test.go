@@ -0,0 +1,76 @@
+package errhandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructured_LogsErrorsWithFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, FormatJSON, "info")
+	assert.NoError(t, err)
+
+	handle := Structured(logger)
+	handle(errors.Wrap(WithFields(errors.New("foo"), "conn", 3, "remote", "127.0.0.1:5123"), "cannot read"))
+	handle(nil)
+
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record), "should log a single json record")
+
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "cannot read: foo", record["msg"])
+	assert.Equal(t, float64(3), record["conn"])
+	assert.Equal(t, "127.0.0.1:5123", record["remote"])
+}
+
+func TestNewLogger_FiltersLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, FormatText, "warn")
+	assert.NoError(t, err)
+
+	logger.Info("started")
+	logger.Warn("slow client", "conn", 1)
+
+	assert.NotContains(t, buf.String(), "started")
+	assert.Contains(t, buf.String(), "level=WARN msg=\"slow client\" conn=1")
+}
+
+func TestNewLogger_RejectsUnknownSettings(t *testing.T) {
+	_, err := NewLogger(&bytes.Buffer{}, "xml", "info")
+	assert.Error(t, err)
+
+	_, err = NewLogger(&bytes.Buffer{}, FormatText, "verbose")
+	assert.Error(t, err)
+}
+
+func TestWithFields_KeepsError(t *testing.T) {
+	cause := errors.New("foo")
+	err := WithFields(cause, "conn", 1)
+
+	assert.Equal(t, "foo", err.Error())
+	assert.Equal(t, cause, errors.Cause(err))
+	assert.Nil(t, WithFields(nil, "conn", 1))
+	assert.Empty(t, Fields(cause))
+}
+
+func TestErrLogger_KeepsGlobalPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetPrefix("[global] ")
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetPrefix("")
+	}()
+
+	Logger("[error] ")(errors.New("foo"))
+
+	assert.True(t, strings.HasPrefix(buf.String(), "[error] "))
+	assert.Equal(t, "[global] ", log.Prefix())
+}
@@ -0,0 +1,35 @@
+package line
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnterminatedLine error returned when input does not end on carriage-return
+var ErrUnterminatedLine = errors.New("unterminated line")
+
+// ParseNumberLines parses a whole datagram of number lines, failing if any line is not a valid number
+// termination lines are not valid on datagrams, as their source cannot be trusted
+func ParseNumberLines(datagram []byte, validator *Validator) (numbers []uint64, err error) {
+	if len(datagram) == 0 || datagram[len(datagram)-1] != '\n' {
+		return nil, ErrUnterminatedLine
+	}
+
+	reader := NewReader(*bufio.NewReader(bytes.NewReader(datagram)), validator)
+
+	for {
+		number, err := reader.ReadNumberLine64()
+		if err == io.EOF {
+			return numbers, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		numbers = append(numbers, number)
+	}
+}
@@ -0,0 +1,36 @@
+package line
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNumberLines_ParsesAllLines(t *testing.T) {
+	validator, err := NewValidator()
+	assert.NoError(t, err)
+
+	numbers, err := ParseNumberLines([]byte("007007009\n314159265\n"), validator)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{7007009, 314159265}, numbers)
+}
+
+func TestParseNumberLines_FailsOnAnyInvalidLine(t *testing.T) {
+	validator, err := NewValidator()
+	assert.NoError(t, err)
+
+	invalidDatagrams := []string{
+		"",
+		"314159265",
+		"314159265\n007007009",
+		"314159265\nshort\n",
+		"314159265\nterminate\n",
+	}
+
+	for _, d := range invalidDatagrams {
+		_, err := ParseNumberLines([]byte(d), validator)
+
+		assert.Error(t, err, d)
+	}
+}
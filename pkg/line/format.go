@@ -0,0 +1,84 @@
+package line
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Newline line ending accepted
+type Newline string
+
+// newline styles
+const (
+	NewlineLF   Newline = "lf"
+	NewlineCRLF Newline = "crlf"
+	NewlineAny  Newline = "any"
+)
+
+// max digits fitting on uint64
+const maxDigits = 19
+
+// Format defines valid number lines
+// * Newline: line ending accepted
+// * Digits: exact amount of digits, leading zeros included
+// * Min, Max: optional range of valid numbers, no upper bound if Max is 0
+type Format struct {
+	Newline Newline
+	Digits  int
+	Min     uint64
+	Max     uint64
+}
+
+// DefaultFormat 9-digit numbers ending on server-native newline
+var DefaultFormat = Format{
+	Newline: NewlineLF,
+	Digits:  9,
+}
+
+// Validate returns error if format is not valid
+func (f Format) Validate() error {
+	switch f.Newline {
+	case NewlineLF, NewlineCRLF, NewlineAny:
+	default:
+		return errors.Errorf("unknown newline %q, expected one of: %s, %s, %s", f.Newline, NewlineLF, NewlineCRLF, NewlineAny)
+	}
+
+	if f.Digits < 1 || f.Digits > maxDigits {
+		return errors.Errorf("digits should be between 1 and %d, got %d", maxDigits, f.Digits)
+	}
+
+	if f.Max != 0 && f.Min > f.Max {
+		return errors.Errorf("min %d is greater than max %d", f.Min, f.Max)
+	}
+
+	if f.Min > f.MaxValue() {
+		return errors.Errorf("min %d does not fit on %d digits", f.Min, f.Digits)
+	}
+
+	return nil
+}
+
+// MaxValue returns the max valid number, bounded by digits and Max
+func (f Format) MaxValue() uint64 {
+	max := uint64(1)
+	for i := 0; i < f.Digits; i++ {
+		max *= 10
+	}
+	max--
+
+	if f.Max != 0 && f.Max < max {
+		return f.Max
+	}
+
+	return max
+}
+
+func (f Format) newlineRegex() string {
+	switch f.Newline {
+	case NewlineCRLF:
+		return `\r\n`
+	case NewlineAny:
+		return `\r?\n`
+	}
+
+	return `\n`
+}
@@ -0,0 +1,91 @@
+package line
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat_Validate(t *testing.T) {
+	assert.NoError(t, DefaultFormat.Validate())
+	assert.NoError(t, Format{Newline: NewlineAny, Digits: 19}.Validate())
+
+	invalidFormats := []Format{
+		{Newline: "cr", Digits: 9},
+		{Newline: NewlineLF, Digits: 0},
+		{Newline: NewlineLF, Digits: 20},
+		{Newline: NewlineLF, Digits: 9, Min: 10, Max: 5},
+		{Newline: NewlineLF, Digits: 2, Min: 100},
+	}
+
+	for _, f := range invalidFormats {
+		assert.Error(t, f.Validate(), "%+v", f)
+	}
+}
+
+func TestFormatValidator_CRLF(t *testing.T) {
+	v, err := NewFormatValidator(Format{Newline: NewlineCRLF, Digits: 9})
+	assert.NoError(t, err)
+
+	assert.True(t, v.IsValidLine("123456789\r\n"))
+	assert.True(t, v.IsValidLine("terminate\r\n"))
+	assert.True(t, v.IsValidLine("terminate s3cret\r\n"))
+	assert.False(t, v.IsValidLine("123456789\n"))
+}
+
+func TestFormatValidator_AnyNewline(t *testing.T) {
+	v, err := NewFormatValidator(Format{Newline: NewlineAny, Digits: 9})
+	assert.NoError(t, err)
+
+	assert.True(t, v.IsValidLine("123456789\r\n"))
+	assert.True(t, v.IsValidLine("123456789\n"))
+	assert.False(t, v.IsValidLine("123456789\r"))
+}
+
+func TestFormatValidator_Digits(t *testing.T) {
+	v, err := NewFormatValidator(Format{Newline: NewlineLF, Digits: 12})
+	assert.NoError(t, err)
+
+	assert.True(t, v.IsValidLine("000123456789\n"))
+	assert.False(t, v.IsValidLine("123456789\n"))
+
+	n, err := v.ParseNumber("999999999999")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(999999999999), n)
+}
+
+func TestFormatValidator_Range(t *testing.T) {
+	v, err := NewFormatValidator(Format{Newline: NewlineLF, Digits: 9, Min: 100, Max: 200})
+	assert.NoError(t, err)
+
+	for _, valid := range []string{"000000100", "000000200"} {
+		_, err := v.ParseNumber(valid)
+		assert.NoError(t, err, valid)
+	}
+
+	for _, invalid := range []string{"000000099", "000000201"} {
+		_, err := v.ParseNumber(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestLineReader_ReadsCRLFLines(t *testing.T) {
+	v, err := NewFormatValidator(Format{Newline: NewlineCRLF, Digits: 9})
+	assert.NoError(t, err)
+
+	r := NewReader(*bufio.NewReader(strings.NewReader("hello ack\r\n123456789\r\nterminate s3cret\r\n")), v)
+
+	protocol, err := r.ReadHello()
+	assert.NoError(t, err)
+	assert.Equal(t, "ack", protocol)
+
+	n, err := r.ReadNumberLine()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(123456789), n)
+
+	_, err = r.ReadNumberLine()
+	assert.Equal(t, ErrTermination, err)
+	assert.Equal(t, "s3cret", r.TerminationToken())
+}
@@ -0,0 +1,35 @@
+package line
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReadQueryLine reads a line of one or more space separated valid numbers
+// returns io.EOF on input end
+func (r *Reader) ReadQueryLine() (numbers []uint64, err error) {
+	line, err := r.reader.ReadString('\n')
+	if err == io.EOF {
+		return
+	}
+
+	if err != nil {
+		err = readError{errors.Wrap(err, "cannot read input")}
+		return
+	}
+
+	fields := strings.Split(trimNewline(line), " ")
+	for _, field := range fields {
+		number, err := r.validator.ParseNumber(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query line: %s", line)
+		}
+
+		numbers = append(numbers, number)
+	}
+
+	return
+}
@@ -0,0 +1,34 @@
+package line
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineReader_ReadQueryLine(t *testing.T) {
+	validator, err := NewValidator()
+	assert.NoError(t, err)
+
+	r := NewReader(*bufio.NewReader(strings.NewReader("314159265\n314159265 007007009\n314159265  007007009\nterminate\n")), validator)
+
+	numbers, err := r.ReadQueryLine()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{314159265}, numbers)
+
+	numbers, err = r.ReadQueryLine()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{314159265, 7007009}, numbers)
+
+	_, err = r.ReadQueryLine()
+	assert.Error(t, err, "fields should be separated by a single space")
+
+	_, err = r.ReadQueryLine()
+	assert.Error(t, err, "termination is not a query")
+
+	_, err = r.ReadQueryLine()
+	assert.Equal(t, io.EOF, err)
+}
@@ -6,22 +6,26 @@ import (
 	"fmt"
 
 	"io"
+	"math"
 
-	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
 )
 
-const terminationLine = "terminate"
+const (
+	terminationLine = "terminate"
+	helloLine       = "hello "
+)
 
 // ErrTermination error returned on termination input read
 var ErrTermination = errors.New("termination")
 
 // Reader reads lines to return valid numbers or termination
 type Reader struct {
-	reader    bufio.Reader
-	validator *Validator
+	reader           bufio.Reader
+	validator        *Validator
+	terminationToken string
 }
 
 // NewReader reads number lines
@@ -32,18 +36,76 @@ func NewReader(reader bufio.Reader, validator *Validator) *Reader {
 	}
 }
 
+// ReadHello reads the optional protocol negotiation first line "hello <protocol>"
+// returns empty protocol, consuming nothing, if input does not start with it
+func (r *Reader) ReadHello() (protocol string, err error) {
+	prefix, err := r.reader.Peek(len(helloLine))
+	if err == io.EOF {
+		return "", nil
+	}
+	if err != nil {
+		return "", readError{errors.Wrap(err, "cannot read input")}
+	}
+
+	if string(prefix) != helloLine {
+		return "", nil
+	}
+
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return "", readError{errors.Wrap(err, "cannot read hello")}
+	}
+
+	protocol = trimNewline(strings.TrimPrefix(line, helloLine))
+	if protocol == "" || strings.ContainsAny(protocol, " \t\r") {
+		return "", fmt.Errorf("invalid hello line: %s", line)
+	}
+
+	return protocol, nil
+}
+
 // ReadNumberLine reads a valid line or returns error
 // special returned errors:
 // * io.EOF: on input end
-// * terminationError: on termination input
+// * ErrTermination: on termination input, its token is available through TerminationToken
 func (r *Reader) ReadNumberLine() (number uint32, err error) {
-	line, err := r.reader.ReadString('\n')
+	line, err := r.readNumberLine()
+	if err != nil {
+		return
+	}
+
+	number64, err := r.validator.parseValidLine(line)
+	if err != nil {
+		return
+	}
+
+	if number64 > math.MaxUint32 {
+		err = fmt.Errorf("number does not fit on 32 bits: %d", number64)
+		return
+	}
+
+	return uint32(number64), nil
+}
+
+// ReadNumberLine64 reads a valid line of up to 19 digits, returning same errors as ReadNumberLine
+func (r *Reader) ReadNumberLine64() (number uint64, err error) {
+	line, err := r.readNumberLine()
+	if err != nil {
+		return
+	}
+
+	return r.validator.parseValidLine(line)
+}
+
+// readNumberLine reads a valid number line without line ending, returning same errors as ReadNumberLine
+func (r *Reader) readNumberLine() (line string, err error) {
+	line, err = r.reader.ReadString('\n')
 	if err == io.EOF {
 		return
 	}
 
 	if err != nil {
-		err = errors.Wrap(err, "cannot read input")
+		err = readError{errors.Wrap(err, "cannot read input")}
 		return
 	}
 
@@ -52,20 +114,44 @@ func (r *Reader) ReadNumberLine() (number uint32, err error) {
 		return
 	}
 
-	line = strings.TrimSuffix(line, "\n")
+	line = trimNewline(line)
 
-	if line == terminationLine {
+	if strings.HasPrefix(line, terminationLine) {
+		r.terminationToken = strings.TrimPrefix(strings.TrimPrefix(line, terminationLine), " ")
 		err = ErrTermination
 		return
 	}
 
-	numberInt, err := strconv.Atoi(line)
-	if err != nil {
-		err = errors.Wrapf(err, "cannot convert line to int %s", line)
-		return
-	}
+	return line, nil
+}
+
+// TerminationToken returns the token sent on the last termination line read, empty if none
+func (r *Reader) TerminationToken() string {
+	return r.terminationToken
+}
+
+// Buffered returns the amount of input bytes already read but not consumed, 0 means next read may block
+func (r *Reader) Buffered() int {
+	return r.reader.Buffered()
+}
+
+// readError error reading input, as opposed to invalid input
+type readError struct {
+	error
+}
 
-	number = uint32(numberInt)
+// Cause enables github.com/pkg/errors Cause
+func (e readError) Cause() error { return e.error }
+
+func (e readError) Unwrap() error { return e.error }
+
+// IsReadError returns true if err comes from reading input instead of from its content, e.g: a closed connection
+func IsReadError(err error) bool {
+	_, ok := err.(readError)
+	return ok
+}
 
-	return
+// trimNewline removes line ending, either LF or CRLF
+func trimNewline(line string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
 }
@@ -2,6 +2,7 @@ package line
 
 import (
 	"bufio"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -43,3 +44,107 @@ func TestLineReader_ReturnsEOFErrorOnEndOfFile(t *testing.T) {
 
 	assert.Equal(t, io.EOF, err)
 }
+
+func TestLineReader_ReturnsTerminationWithToken(t *testing.T) {
+	validator, err := NewValidator()
+	assert.NoError(t, err)
+
+	r := NewReader(*bufio.NewReader(strings.NewReader("terminate\nterminate s3cret\n")), validator)
+
+	_, err = r.ReadNumberLine()
+
+	assert.Equal(t, ErrTermination, err)
+	assert.Equal(t, "", r.TerminationToken())
+
+	_, err = r.ReadNumberLine()
+
+	assert.Equal(t, ErrTermination, err)
+	assert.Equal(t, "s3cret", r.TerminationToken())
+}
+
+func TestLineReader_ReadsHelloProtocol(t *testing.T) {
+	validator, err := NewValidator()
+	assert.NoError(t, err)
+
+	r := NewReader(*bufio.NewReader(strings.NewReader("hello ack\n123456789\n")), validator)
+
+	protocol, err := r.ReadHello()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ack", protocol)
+
+	line, err := r.ReadNumberLine()
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(123456789), line)
+}
+
+func TestLineReader_ReadHelloConsumesNothingWithoutHello(t *testing.T) {
+	validator, err := NewValidator()
+	assert.NoError(t, err)
+
+	for _, input := range []string{"", "123456789\n"} {
+		r := NewReader(*bufio.NewReader(strings.NewReader(input)), validator)
+
+		protocol, err := r.ReadHello()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "", protocol)
+	}
+
+	r := NewReader(*bufio.NewReader(strings.NewReader("123456789\n")), validator)
+	_, _ = r.ReadHello()
+
+	line, err := r.ReadNumberLine()
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(123456789), line)
+}
+
+// BenchmarkReader reads 9-digit lines, the default format fast path
+func BenchmarkReader(b *testing.B) {
+	validator, err := NewValidator()
+	assert.NoError(b, err)
+
+	benchmarkReader(b, validator)
+}
+
+// BenchmarkReader_Ranged reads 9-digit lines checking their range
+func BenchmarkReader_Ranged(b *testing.B) {
+	validator, err := NewFormatValidator(Format{Newline: NewlineLF, Digits: 9, Min: 1, Max: 999999998})
+	assert.NoError(b, err)
+
+	benchmarkReader(b, validator)
+}
+
+func benchmarkReader(b *testing.B, validator *Validator) {
+	var lines strings.Builder
+	for i := 0; i < 1000; i++ {
+		lines.WriteString(fmt.Sprintf("%09d\n", 314159265+i))
+	}
+
+	r := NewReader(*bufio.NewReader(&repeatReader{input: lines.String()}), validator)
+
+	b.SetBytes(10)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := r.ReadNumberLine()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// repeatReader reads its input over and over
+type repeatReader struct {
+	input  string
+	offset int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := copy(p, r.input[r.offset:])
+	r.offset = (r.offset + n) % len(r.input)
+
+	return n, nil
+}
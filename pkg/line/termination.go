@@ -0,0 +1,95 @@
+package line
+
+import (
+	"crypto/subtle"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// termination authorization errors
+var (
+	ErrTerminationDisabled = errors.New("termination is disabled")
+	ErrTerminationSource   = errors.New("termination not allowed from source")
+	ErrTerminationToken    = errors.New("invalid termination token")
+)
+
+// TerminationPolicy authorizes termination lines, its zero value allows anyone to terminate
+// * Disabled: termination lines are always rejected
+// * Networks: only sources within these networks may terminate, any source if empty
+// * Token: termination line must be "terminate <token>", plain "terminate" if empty
+type TerminationPolicy struct {
+	Disabled bool
+	Networks []*net.IPNet
+	Token    string
+}
+
+// ParseNetworks parses a list of CIDRs, e.g: 127.0.0.1/32,10.0.0.0/8
+func ParseNetworks(cidrs []string) (networks []*net.IPNet, err error) {
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network %s", cidr)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return
+}
+
+// Authorize returns nil if the termination line sent by source with given token is allowed
+func (p TerminationPolicy) Authorize(source net.Addr, token string) error {
+	if p.Disabled {
+		return ErrTerminationDisabled
+	}
+
+	if len(p.Networks) > 0 && !p.containsSource(source) {
+		return ErrTerminationSource
+	}
+
+	if subtle.ConstantTimeCompare([]byte(p.Token), []byte(token)) != 1 {
+		return ErrTerminationToken
+	}
+
+	return nil
+}
+
+func (p TerminationPolicy) containsSource(source net.Addr) bool {
+	ip := sourceIP(source)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range p.Networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sourceIP(source net.Addr) net.IP {
+	switch addr := source.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	case nil:
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(source.String())
+	if err != nil {
+		return nil
+	}
+
+	return net.ParseIP(host)
+}
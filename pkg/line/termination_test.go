@@ -0,0 +1,51 @@
+package line
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	localSource  = &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 50000}
+	remoteSource = &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 50000}
+)
+
+func TestTerminationPolicy_ZeroValueAllowsAnySource(t *testing.T) {
+	p := TerminationPolicy{}
+
+	assert.NoError(t, p.Authorize(localSource, ""))
+	assert.NoError(t, p.Authorize(remoteSource, ""))
+}
+
+func TestTerminationPolicy_DisabledRejectsAll(t *testing.T) {
+	p := TerminationPolicy{Disabled: true}
+
+	assert.Equal(t, ErrTerminationDisabled, p.Authorize(localSource, ""))
+}
+
+func TestTerminationPolicy_NetworksRestrictSources(t *testing.T) {
+	networks, err := ParseNetworks([]string{"127.0.0.1/32", " 10.0.0.0/8 ", ""})
+	assert.NoError(t, err)
+	assert.Len(t, networks, 2)
+
+	p := TerminationPolicy{Networks: networks}
+
+	assert.NoError(t, p.Authorize(localSource, ""))
+	assert.Equal(t, ErrTerminationSource, p.Authorize(remoteSource, ""))
+}
+
+func TestTerminationPolicy_TokenMustMatch(t *testing.T) {
+	p := TerminationPolicy{Token: "s3cret"}
+
+	assert.NoError(t, p.Authorize(remoteSource, "s3cret"))
+	assert.Equal(t, ErrTerminationToken, p.Authorize(remoteSource, ""))
+	assert.Equal(t, ErrTerminationToken, p.Authorize(remoteSource, "wrong"))
+}
+
+func TestParseNetworks_ReturnsErrorOnInvalidCIDR(t *testing.T) {
+	_, err := ParseNetworks([]string{"127.0.0.1"})
+
+	assert.Error(t, err)
+}
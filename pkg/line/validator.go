@@ -3,23 +3,84 @@ package line
 import (
 	"fmt"
 	"regexp"
+
+	"github.com/pkg/errors"
 )
 
 // Validator validates number lines
 type Validator struct {
-	regex *regexp.Regexp
+	regex       *regexp.Regexp
+	numberRegex *regexp.Regexp
+	format      Format
+	// precomputed as checked on every line
+	maxValue uint64
+	// numbers of valid digits are only out of range if bounded by Min or Max
+	ranged bool
 }
 
-// NewValidator validates line is 9-digit or "termination" ending on carriage-return
+// NewValidator validates line is 9-digit or "terminate" with an optional token, ending on carriage-return
 func NewValidator() (*Validator, error) {
-	regex, err := regexp.Compile(fmt.Sprintf(`^(\d{9}|%s)\n$`, terminationLine))
+	return NewFormatValidator(DefaultFormat)
+}
+
+// NewFormatValidator validates line is a number on given format or "terminate" with an optional token
+func NewFormatValidator(format Format) (*Validator, error) {
+	err := format.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid line format")
+	}
+
+	regex, err := regexp.Compile(fmt.Sprintf(`^(\d{%d}|%s( \S+)?)%s$`, format.Digits, terminationLine, format.newlineRegex()))
+	if err != nil {
+		return nil, err
+	}
+
+	numberRegex, err := regexp.Compile(fmt.Sprintf(`^\d{%d}$`, format.Digits))
 
 	return &Validator{
-		regex: regex,
+		regex:       regex,
+		numberRegex: numberRegex,
+		format:      format,
+		maxValue:    format.MaxValue(),
+		ranged:      format.Min > 0 || format.Max != 0,
 	}, err
 }
 
-// IsValidLine returns true if valid
+// IsValidLine returns true if valid, number range is checked on parse
 func (r *Validator) IsValidLine(line string) bool {
 	return r.regex.MatchString(line)
 }
+
+// ParseNumber parses a number without line ending, validating its digits and range
+func (r *Validator) ParseNumber(number string) (uint64, error) {
+	if !r.numberRegex.MatchString(number) {
+		return 0, fmt.Errorf("invalid number: %s", number)
+	}
+
+	return r.parseValidLine(number)
+}
+
+// parseValidLine parses a number line without line ending already matched by IsValidLine, checking only its range
+func (r *Validator) parseValidLine(line string) (uint64, error) {
+	n := parseDigits(line)
+
+	if r.ranged && !r.InRange(n) {
+		return 0, fmt.Errorf("number out of range: %s", line)
+	}
+
+	return n, nil
+}
+
+// InRange returns true if number fits on format digits and range
+func (r *Validator) InRange(number uint64) bool {
+	return number >= r.format.Min && number <= r.maxValue
+}
+
+// parseDigits parses up to 19 validated decimal digits, which always fit on 64 bits
+func parseDigits(digits string) (n uint64) {
+	for i := 0; i < len(digits); i++ {
+		n = n*10 + uint64(digits[i]-'0')
+	}
+
+	return n
+}
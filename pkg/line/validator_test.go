@@ -12,6 +12,7 @@ func TestLineValidator_ReturnsTrueOnValidLines(t *testing.T) {
 		"314159265\n",
 		"007007009\n",
 		"terminate\n",
+		"terminate s3cret\n",
 	}
 
 	v, err := NewValidator()
@@ -61,3 +62,18 @@ func TestLineValidator_ReturnsFalseOnEOFWithoutCarriageReturn(t *testing.T) {
 
 	assert.False(t, v.IsValidLine("123456789"))
 }
+
+func TestLineValidator_ReturnsFalseOnMalformedTermination(t *testing.T) {
+	lines := []string{
+		"terminate \n",
+		"terminatenow\n",
+		"terminate two tokens\n",
+	}
+
+	v, err := NewValidator()
+	assert.NoError(t, err)
+
+	for _, l := range lines {
+		assert.False(t, v.IsValidLine(l))
+	}
+}
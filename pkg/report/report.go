@@ -10,11 +10,26 @@ import (
 // * The difference since the last report of the count of new duplicate numbers that have been received.
 // * The total number of unique numbers received for this run of the Application.
 // * Example text: Received 50 unique numbers, 2 duplicates. Unique total: 567231
+// Besides, when there are invalid datagrams received or numbers expired since last report their count is appended,
+// as well as any stat added.
 type Report struct {
 	sync.Mutex
 	uniqueDiff    uint
 	duplicateDiff uint
 	uniqueTotal   uint
+	droppedDiff   uint
+	expiredDiff   uint
+	stats         []Stat
+}
+
+// Stat returns a text to be appended on each report, e.g: "Bloom false positive rate: 0.01%"
+type Stat func() string
+
+// AddStat appends given stat to every report
+func (r *Report) AddStat(stat Stat) {
+	r.Lock()
+	r.stats = append(r.stats, stat)
+	r.Unlock()
 }
 
 // Increase increases count for unique or duplicated
@@ -29,20 +44,50 @@ func (r *Report) Increase(unique bool) {
 	r.Unlock()
 }
 
+// IncreaseDropped increases count of invalid datagrams dropped
+func (r *Report) IncreaseDropped() {
+	r.Lock()
+	r.droppedDiff++
+	r.Unlock()
+}
+
+// IncreaseExpired increases count of numbers expired, so they are unique again
+func (r *Report) IncreaseExpired(amount uint) {
+	r.Lock()
+	r.expiredDiff += amount
+	r.Unlock()
+}
+
 // ReportTransaction retrieves report as human readable text starting a transaction to be committed or rollbacked
 func (r *Report) ReportTransaction() string {
 	r.Lock()
-	return fmt.Sprintf("Received %d unique numbers, %d duplicates. Unique total: %d\n",
+	text := fmt.Sprintf("Received %d unique numbers, %d duplicates. Unique total: %d",
 		r.uniqueDiff,
 		r.duplicateDiff,
 		r.uniqueTotal,
 	)
+
+	if r.droppedDiff > 0 {
+		text = fmt.Sprintf("%s. Dropped %d invalid datagrams", text, r.droppedDiff)
+	}
+
+	if r.expiredDiff > 0 {
+		text = fmt.Sprintf("%s. Expired %d numbers", text, r.expiredDiff)
+	}
+
+	for _, stat := range r.stats {
+		text = fmt.Sprintf("%s. %s", text, stat())
+	}
+
+	return text + "\n"
 }
 
 // Commit unlocks and reset a new period count
 func (r *Report) Commit() {
 	r.uniqueDiff = 0
 	r.duplicateDiff = 0
+	r.droppedDiff = 0
+	r.expiredDiff = 0
 
 	r.Unlock()
 }
@@ -1,9 +1,15 @@
 package report
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/errhandler"
 )
 
 func TestReport_IncreaseUniqueTrue(t *testing.T) {
@@ -66,3 +72,160 @@ func TestReport_SeveralPeriods(t *testing.T) {
 	assert.Equal(t, uint(2), r.uniqueTotal)
 	assert.Equal(t, uint(0), r.duplicateDiff)
 }
+
+func TestReport_ReportTransactionText(t *testing.T) {
+	r := Report{}
+
+	r.Increase(true)
+	r.Increase(false)
+
+	assert.Equal(t, "Received 1 unique numbers, 1 duplicates. Unique total: 1\n", r.ReportTransaction())
+	r.Commit()
+
+	r.IncreaseDropped()
+
+	assert.Equal(t, "Received 0 unique numbers, 0 duplicates. Unique total: 1. Dropped 1 invalid datagrams\n", r.ReportTransaction())
+	r.Commit()
+
+	assert.Equal(t, uint(0), r.droppedDiff)
+}
+
+func TestReport_ExpiredCount(t *testing.T) {
+	r := Report{}
+
+	r.IncreaseExpired(3)
+	r.IncreaseExpired(2)
+
+	assert.Equal(t, "Received 0 unique numbers, 0 duplicates. Unique total: 0. Expired 5 numbers\n", r.ReportTransaction())
+	r.Commit()
+
+	assert.Equal(t, "Received 0 unique numbers, 0 duplicates. Unique total: 0\n", r.ReportTransaction())
+	r.Commit()
+}
+
+func TestReport_AppendsStats(t *testing.T) {
+	r := Report{}
+
+	r.AddStat(func() string { return "Epoch: 1" })
+	r.AddStat(func() string { return "Bloom false positive rate: 0.01%" })
+
+	assert.Equal(t, "Received 0 unique numbers, 0 duplicates. Unique total: 0. Epoch: 1. Bloom false positive rate: 0.01%\n", r.ReportTransaction())
+	r.Commit()
+}
+
+func TestRunner_WritesReportsToEveryOutput(t *testing.T) {
+	r := &Report{}
+	r.Increase(true)
+	r.Increase(false)
+
+	stdout, file := &syncBuffer{}, &syncBuffer{}
+	runner := NewRunnerWithWriters(time.Hour, r, stdout, errhandler.Noop, file)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// reports once on stop
+	assert.NoError(t, runner.Run(ctx))
+
+	expected := "Received 1 unique numbers, 1 duplicates. Unique total: 1\n"
+	assert.Equal(t, expected, stdout.String())
+	assert.Equal(t, expected, file.String())
+}
+
+func TestRunner_ReportsOnEachInterval(t *testing.T) {
+	r := &Report{}
+	output := &syncBuffer{}
+	runner := NewRunnerWithWriter(10*time.Millisecond, r, output)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runner.Run(ctx)
+	}()
+
+	r.Increase(true)
+	time.Sleep(50 * time.Millisecond)
+	r.Increase(true)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	assert.Contains(t, output.String(), "Received 1 unique numbers, 0 duplicates. Unique total: 1\n")
+	assert.Contains(t, output.String(), "Received 1 unique numbers, 0 duplicates. Unique total: 2\n")
+	assert.Contains(t, output.String(), "Received 0 unique numbers, 0 duplicates. Unique total: 1\n")
+}
+
+func TestRunner_KeepsCountsOnWriteError(t *testing.T) {
+	r := &Report{}
+	r.Increase(true)
+
+	runner := NewRunnerWithWriters(time.Hour, r, failingWriter{}, errhandler.Noop, &syncBuffer{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Error(t, runner.Run(ctx))
+	assert.Equal(t, uint(1), r.uniqueDiff, "counts should be kept to be reported again")
+}
+
+func TestRunner_KeepsReportingOnOptionalOutputError(t *testing.T) {
+	r := &Report{}
+	output := &syncBuffer{}
+
+	var handled []error
+	var handledLock sync.Mutex
+	errHandle := func(err error) {
+		handledLock.Lock()
+		handled = append(handled, err)
+		handledLock.Unlock()
+	}
+
+	runner := NewRunnerWithWriters(10*time.Millisecond, r, output, errHandle, failingWriter{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runner.Run(ctx)
+	}()
+
+	r.Increase(true)
+	time.Sleep(50 * time.Millisecond)
+	r.Increase(true)
+
+	cancel()
+	assert.NoError(t, <-done, "optional output errors should not stop the runner")
+
+	assert.Contains(t, output.String(), "Received 1 unique numbers, 0 duplicates. Unique total: 1\n")
+	assert.Contains(t, output.String(), "Received 1 unique numbers, 0 duplicates. Unique total: 2\n")
+
+	handledLock.Lock()
+	defer handledLock.Unlock()
+	if assert.NotEmpty(t, handled) {
+		assert.Contains(t, handled[0].Error(), "disk full")
+	}
+	assert.Equal(t, uint(0), r.uniqueDiff, "counts written to output should be committed")
+}
+
+// syncBuffer buffer safe to write and read concurrently
+type syncBuffer struct {
+	buffer bytes.Buffer
+	sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.String()
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
@@ -0,0 +1,133 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile appends reports to a file, rotating it before exceeding its max size:
+// the file is moved to path.1, path.1 to path.2 and so on, keeping up to backups files
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+	sync.Mutex
+}
+
+// NewRotatingFile opens given file to append to it, never rotated if maxSize is 0
+func NewRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	if maxSize < 0 || backups < 0 {
+		return nil, fmt.Errorf("max size and backups cannot be negative, got %d and %d", maxSize, backups)
+	}
+
+	f := &RotatingFile{
+		path:    path,
+		maxSize: maxSize,
+		backups: backups,
+	}
+
+	err := f.open(os.O_APPEND)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first if p does not fit
+// if rotation fails p is appended to the current file, returning the rotation error, and it is retried on next write
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	var rotateErr error
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		rotateErr = f.rotate()
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	if err == nil {
+		err = rotateErr
+	}
+
+	return n, err
+}
+
+// Close closes the file
+func (f *RotatingFile) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.file.Close()
+}
+
+// rotate shifts backups dropping the oldest one, and starts a new file
+// on failure the current file is reopened to append to it
+func (f *RotatingFile) rotate() error {
+	err := f.shift()
+	if err != nil {
+		openErr := f.open(os.O_APPEND)
+		if openErr != nil {
+			return fmt.Errorf("%s, %s", err.Error(), openErr.Error())
+		}
+
+		return err
+	}
+
+	return f.open(os.O_TRUNC)
+}
+
+// shift moves the file to the first backup and each backup to the next one
+func (f *RotatingFile) shift() error {
+	for i := f.backups - 1; i > 0; i-- {
+		err := os.Rename(f.backupPath(i), f.backupPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot rotate report file: %s", err.Error())
+		}
+	}
+
+	if f.backups > 0 {
+		err := os.Rename(f.path, f.backupPath(1))
+		if err != nil {
+			return fmt.Errorf("cannot rotate report file: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// open opens the file with given mode flag, creating it if needed, and closes the previous one if any,
+// which is kept on failure
+func (f *RotatingFile) open(mode int) error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|mode, 0666)
+	if err != nil {
+		return fmt.Errorf("cannot open report file: %s", err.Error())
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("cannot open report file: %s", err.Error())
+	}
+
+	previous := f.file
+	f.file, f.size = file, info.Size()
+
+	if previous != nil {
+		err = previous.Close()
+		if err != nil {
+			return fmt.Errorf("cannot close report file: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (f *RotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
@@ -0,0 +1,81 @@
+package report
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile_RotatesKeepingBackups(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "reports.log")
+
+	f, err := NewRotatingFile(path, 8, 2)
+	assert.NoError(t, err)
+
+	for _, report := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = f.Write([]byte(report))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, f.Close())
+
+	assertFileContent(t, path, "fourth\n")
+	assertFileContent(t, path+".1", "third\n")
+	assertFileContent(t, path+".2", "second\n")
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "oldest backup should be dropped")
+}
+
+func TestRotatingFile_AppendsToExistingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "reports.log")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("previous\n"), 0644))
+
+	f, err := NewRotatingFile(path, 0, 0)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte("next\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	assertFileContent(t, path, "previous\nnext\n")
+}
+
+func TestRotatingFile_KeepsWritingOnFailedRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "reports.log")
+
+	// a non-empty directory on the backup path makes renaming the file fail
+	assert.NoError(t, os.MkdirAll(filepath.Join(path+".1", "blocker"), 0755))
+
+	f, err := NewRotatingFile(path, 8, 1)
+	assert.NoError(t, err)
+
+	_, err = f.Write([]byte("first\n"))
+	assert.NoError(t, err)
+	n, err := f.Write([]byte("second\n"))
+	assert.Error(t, err, "rotation error should be returned")
+	assert.Equal(t, len("second\n"), n, "report should be written despite rotation error")
+
+	assertFileContent(t, path, "first\nsecond\n")
+
+	// rotation is retried once possible
+	assert.NoError(t, os.RemoveAll(path+".1"))
+	_, err = f.Write([]byte("third\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	assertFileContent(t, path, "third\n")
+	assertFileContent(t, path+".1", "first\nsecond\n")
+}
+
+func assertFileContent(t *testing.T, path string, expected string) {
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
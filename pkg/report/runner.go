@@ -2,35 +2,88 @@ package report
 
 import (
 	"context"
+	"io"
 	"os"
+	"sync"
 	"time"
+
+	"github.com/pkg/errors"
+	"github.com/varas/numserver/pkg/errhandler"
 )
 
-// Runner prints a report to standard output every 10 seconds
+// Runner writes a report to its output, standard output by default, on each interval
 type Runner struct {
 	interval time.Duration
-	output   *os.File
-	count    *Report
+	output   io.Writer
+	// outputs whose write errors are handled without stopping the runner
+	optional  []io.Writer
+	errHandle errhandler.ErrHandler
+	count     *Report
+	// interval set at runtime, applied by Run
+	nextInterval    time.Duration
+	intervalChanged chan struct{}
+	sync.Mutex
 }
 
-// NewRunner creates a report runner daemon
+// NewRunner creates a report runner daemon printing to standard output
 func NewRunner(interval time.Duration, report *Report) *Runner {
+	return NewRunnerWithWriter(interval, report, os.Stdout)
+}
+
+// NewRunnerWithWriters creates a report runner daemon writing to output, e.g. standard output, and to each optional one,
+// e.g. a report file. A write error on output fails the report keeping its counts, as with a single output,
+// while write errors on optional ones are handled by errHandle, missing that report
+func NewRunnerWithWriters(interval time.Duration, report *Report, output io.Writer, errHandle errhandler.ErrHandler, optional ...io.Writer) *Runner {
+	runner := NewRunnerWithWriter(interval, report, output)
+	runner.optional = optional
+	runner.errHandle = errHandle
+
+	return runner
+}
+
+// NewRunnerWithWriter creates a report runner daemon writing to given output
+func NewRunnerWithWriter(interval time.Duration, report *Report, output io.Writer) *Runner {
 	return &Runner{
-		interval: interval,
-		output:   os.Stdout,
-		count:    report,
+		interval:  interval,
+		output:    output,
+		errHandle: errhandler.Noop,
+		count:     report,
+		// pending change notification, so setting it never blocks
+		intervalChanged: make(chan struct{}, 1),
+	}
+}
+
+// SetInterval changes the report interval, applied from the next report on
+func (r *Runner) SetInterval(interval time.Duration) {
+	r.Lock()
+	r.nextInterval = interval
+	r.Unlock()
+
+	select {
+	case r.intervalChanged <- struct{}{}:
+	default:
+		// already pending
 	}
 }
 
 // Run runs reporting on each interval
 func (r *Runner) Run(ctx context.Context) (err error) {
 	ticker := time.NewTicker(r.interval)
+	defer func() { ticker.Stop() }()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return r.report()
 
+		case <-r.intervalChanged:
+			r.Lock()
+			r.interval = r.nextInterval
+			r.Unlock()
+
+			ticker.Stop()
+			ticker = time.NewTicker(r.interval)
+
 		case <-ticker.C:
 			err = r.report()
 			if err != nil {
@@ -41,12 +94,21 @@ func (r *Runner) Run(ctx context.Context) (err error) {
 }
 
 func (r *Runner) report() error {
-	_, err := r.output.WriteString(r.count.ReportTransaction())
+	text := r.count.ReportTransaction()
+
+	_, err := io.WriteString(r.output, text)
 	if err != nil {
 		r.count.Rollback()
 		return err
 	}
 	r.count.Commit()
 
+	for _, output := range r.optional {
+		_, err = io.WriteString(output, text)
+		if err != nil {
+			r.errHandle(errors.Wrap(err, "cannot write report"))
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// bitmapFile exact on-disk set of 32-bit numbers, a bit per number on a sparse file
+// so disk is only used by the ranges of numbers set, reads are served by the os page cache
+type bitmapFile struct {
+	fd *os.File
+}
+
+const (
+	// size in bytes to fit all 32-bit numbers
+	bitmapFileSize = (1 << 32) / 8
+	// bytes read or written at once on whole file operations
+	bitmapFileChunk = 1 << 20
+)
+
+// newBitmapFile creates a new empty bitmap file, truncating it if exists
+func newBitmapFile(path string) (*bitmapFile, error) {
+	return openBitmapFile(path, os.O_TRUNC)
+}
+
+// openBitmapFile opens a bitmap file keeping the numbers already set, creating it if not exists
+func openBitmapFile(path string, flag int) (*bitmapFile, error) {
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open bitmap file")
+	}
+
+	// sizing an existing file keeps its content
+	err = fd.Truncate(bitmapFileSize)
+	if err != nil {
+		_ = fd.Close()
+		return nil, errors.Wrap(err, "cannot size bitmap file")
+	}
+
+	return &bitmapFile{fd: fd}, nil
+}
+
+func (b *bitmapFile) contains(number uint32) (bool, error) {
+	var buf [1]byte
+	_, err := b.fd.ReadAt(buf[:], int64(number/8))
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot read bitmap file on %d", number)
+	}
+
+	return buf[0]&(1<<(number%8)) != 0, nil
+}
+
+// set sets all given numbers
+func (b *bitmapFile) set(numbers map[uint32]struct{}) error {
+	var buf [1]byte
+
+	for n := range numbers {
+		offset := int64(n / 8)
+
+		_, err := b.fd.ReadAt(buf[:], offset)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read bitmap file on %d", n)
+		}
+
+		buf[0] |= 1 << (n % 8)
+
+		_, err = b.fd.WriteAt(buf[:], offset)
+		if err != nil {
+			return errors.Wrapf(err, "cannot write bitmap file on %d", n)
+		}
+	}
+
+	return nil
+}
+
+// each calls add with each number set, in ascending order
+func (b *bitmapFile) each(add func(uint32) error) error {
+	chunk := make([]byte, bitmapFileChunk)
+
+	for offset := int64(0); offset < bitmapFileSize; offset += bitmapFileChunk {
+		_, err := b.fd.ReadAt(chunk, offset)
+		if err != nil {
+			return errors.Wrap(err, "cannot read bitmap file")
+		}
+
+		err = eachBit(chunk, uint64(offset)*8, add)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setAscending sets the numbers given in ascending order by each, writing them by chunks
+func (b *bitmapFile) setAscending(each func(set func(uint32) error) error) error {
+	chunk := make([]byte, bitmapFileChunk)
+	current := int64(-1)
+
+	flush := func() error {
+		if current < 0 {
+			return nil
+		}
+
+		_, err := b.fd.WriteAt(chunk, current)
+
+		return errors.Wrap(err, "cannot write bitmap file")
+	}
+
+	err := each(func(number uint32) error {
+		offset := int64(number/8) / bitmapFileChunk * bitmapFileChunk
+
+		if offset != current {
+			err := flush()
+			if err != nil {
+				return err
+			}
+
+			_, err = b.fd.ReadAt(chunk, offset)
+			if err != nil {
+				return errors.Wrap(err, "cannot read bitmap file")
+			}
+			current = offset
+		}
+
+		chunk[int64(number/8)-offset] |= 1 << (number % 8)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+func (b *bitmapFile) close() error {
+	return b.fd.Close()
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitmapFile_SetAndContains(t *testing.T) {
+	dir := t.TempDir()
+
+	b, err := newBitmapFile(filepath.Join(dir, "numbers.bitmap"))
+	if err != nil {
+		t.Fatalf("cannot create bitmap file: %s", err.Error())
+	}
+	defer b.close()
+
+	err = b.set(map[uint32]struct{}{0: {}, 9: {}, 4294967295: {}})
+	assert.NoError(t, err)
+
+	for _, n := range []uint32{0, 9, 4294967295} {
+		found, err := b.contains(n)
+		assert.NoError(t, err)
+		assert.True(t, found, "number %d should be set", n)
+	}
+
+	for _, n := range []uint32{1, 8, 10, 4294967294} {
+		found, err := b.contains(n)
+		assert.NoError(t, err)
+		assert.False(t, found, "number %d should not be set", n)
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import "math"
+
+// bloomFilter approximate membership filter, not concurrency safe
+// false positives are possible, false negatives are not
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64 // amount of bits
+	hashes uint64
+	added  uint64
+}
+
+// newBloomFilter sizes a filter for the expected capacity to keep the given false positive rate
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	n := math.Max(float64(capacity), 1)
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(math.Round(m/n*math.Ln2), 1)
+
+	size := uint64(m)
+
+	return &bloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: uint64(k),
+	}
+}
+
+func (f *bloomFilter) add(number uint64) {
+	h1, h2 := bloomHashes(number)
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.added++
+}
+
+func (f *bloomFilter) mayContain(number uint64) bool {
+	h1, h2 := bloomHashes(number)
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// falsePositiveRate estimates current false positive rate given the amount of numbers added
+func (f *bloomFilter) falsePositiveRate() float64 {
+	k := float64(f.hashes)
+
+	return math.Pow(1-math.Exp(-k*float64(f.added)/float64(f.size)), k)
+}
+
+// bloomHashes returns two independent hashes for double hashing, second one is odd to cover all bits
+func bloomHashes(number uint64) (h1, h2 uint64) {
+	return mix64(number), mix64(number^0x9e3779b97f4a7c15) | 1
+}
+
+// mix64 splitmix64 finalizer
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// BloomRepository stores unique numbers with bounded memory, using a bloom filter to tell new numbers apart
+// and an exact on-disk bitmap as fallback for the suspected duplicates, so no number is wrongly discarded
+// * filter is sized for the expected capacity, false positive rate grows if exceeded, making more disk reads
+// * if a disk read fails the number is handled as unique
+type BloomRepository struct {
+	filter  *bloomFilter
+	numbers *spilledSet
+	sync.Mutex
+}
+
+// NewBloomRepository creates a bloom repository using given path as on-disk fallback, truncating it if exists
+func NewBloomRepository(capacity int, falsePositiveRate float64, fallbackPath string) (*BloomRepository, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("bloom capacity must be positive, got %d", capacity)
+	}
+
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("bloom false positive rate must be between 0 and 1, got %g", falsePositiveRate)
+	}
+
+	disk, err := newBitmapFile(fallbackPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BloomRepository{
+		filter:  newBloomFilter(capacity, falsePositiveRate),
+		numbers: newSpilledSet(disk),
+	}, nil
+}
+
+// AddNumber adds a number if unique returning success
+func (r *BloomRepository) AddNumber(number uint32) (unique bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.contains(number) {
+		return false
+	}
+
+	r.filter.add(uint64(number))
+	r.numbers.add(number)
+
+	return true
+}
+
+// Contains returns true if number was added, either extracted or not
+func (r *BloomRepository) Contains(number uint32) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.contains(number)
+}
+
+func (r *BloomRepository) contains(number uint32) bool {
+	return r.filter.mayContain(uint64(number)) && r.numbers.contains(number)
+}
+
+// ExtractTransaction returns unique numbers list delaying data removal to commit
+func (r *BloomRepository) ExtractTransaction() []uint32 {
+	r.Lock()
+
+	return r.numbers.extract()
+}
+
+// Commit writes extracted numbers on disk and unlocks, keeping them in memory if the write fails
+func (r *BloomRepository) Commit() {
+	r.numbers.commit()
+	r.Unlock()
+}
+
+// Rollback unlocks without data removal
+func (r *BloomRepository) Rollback() {
+	r.Unlock()
+}
+
+// Snapshot writes committed numbers, reading them from disk without blocking ingestion
+func (r *BloomRepository) Snapshot(w io.Writer) error {
+	r.Lock()
+	pending := r.numbers.unpersistedNumbers()
+	r.Unlock()
+
+	return r.numbers.snapshot(w, pending)
+}
+
+// Restore adds snapshot numbers as committed ones
+func (r *BloomRepository) Restore(input io.Reader) error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.numbers.restore(input, func(number uint32) {
+		r.filter.add(uint64(number))
+	})
+}
+
+// FalsePositiveRate returns the estimated false positive rate of the filter given the numbers added,
+// that is the ratio of new numbers requiring a disk read
+func (r *BloomRepository) FalsePositiveRate() float64 {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.filter.falsePositiveRate()
+}
+
+// Close closes the on-disk fallback
+func (r *BloomRepository) Close() error {
+	return r.numbers.close()
+}
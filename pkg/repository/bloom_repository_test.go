@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomRepository_AddNumber(t *testing.T) {
+	r := newTestBloomRepository(t, 0.01)
+
+	for _, n := range []uint32{11, 22, 33} {
+		assert.True(t, r.AddNumber(n))
+	}
+
+	assert.False(t, r.AddNumber(11), "repeated number should not return unique")
+	assert.True(t, r.Contains(22))
+}
+
+func TestBloomRepository_DetectsCommittedDuplicatesOnDisk(t *testing.T) {
+	// high false positive rate forces disk fallback
+	r := newTestBloomRepository(t, 0.5)
+
+	numbers := makeRange(1, 500)
+	for _, n := range numbers {
+		assert.True(t, r.AddNumber(n))
+	}
+
+	assert.Len(t, r.ExtractTransaction(), len(numbers))
+	r.Commit()
+
+	assert.Len(t, r.numbers.nonExtracted, 0, "committed numbers should not be kept in memory")
+
+	for _, n := range numbers {
+		assert.False(t, r.AddNumber(n), "committed number %d should be duplicate", n)
+	}
+
+	unique := 0
+	for _, n := range makeRange(501, 1000) {
+		if r.AddNumber(n) {
+			unique++
+		}
+	}
+	assert.Equal(t, 500, unique, "false positives should be resolved on disk")
+}
+
+func TestBloomRepository_SnapshotDoesNotBlockAdds(t *testing.T) {
+	r := newTestBloomRepository(t, 0.01)
+
+	assertSnapshotDoesNotBlockAdds(t, r)
+}
+
+func TestBloomRepository_Rollback(t *testing.T) {
+	r := newTestBloomRepository(t, 0.01)
+
+	_ = r.AddNumber(11)
+
+	assert.Len(t, r.ExtractTransaction(), 1)
+	r.Rollback()
+
+	assert.Len(t, r.ExtractTransaction(), 1)
+	r.Commit()
+
+	assert.Len(t, r.ExtractTransaction(), 0)
+	r.Commit()
+}
+
+func TestBloomRepository_FalsePositiveRate(t *testing.T) {
+	r := newTestBloomRepository(t, 0.01)
+
+	assert.Equal(t, float64(0), r.FalsePositiveRate())
+
+	_ = r.AddNumber(11)
+
+	assert.True(t, r.FalsePositiveRate() > 0)
+}
+
+func TestNewBloomRepository_FailsOnInvalidSettings(t *testing.T) {
+	_, err := NewBloomRepository(0, 0.01, "unused")
+	assert.Error(t, err)
+
+	_, err = NewBloomRepository(1000, 0, "unused")
+	assert.Error(t, err)
+
+	_, err = NewBloomRepository(1000, 1, "unused")
+	assert.Error(t, err)
+}
+
+func newTestBloomRepository(t *testing.T, falsePositiveRate float64) *BloomRepository {
+	dir := t.TempDir()
+
+	r, err := NewBloomRepository(1000, falsePositiveRate, filepath.Join(dir, "fallback.bitmap"))
+	if err != nil {
+		t.Fatalf("cannot create bloom repository: %s", err.Error())
+	}
+
+	t.Cleanup(func() { _ = r.Close() })
+
+	return r
+}
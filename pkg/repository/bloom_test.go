@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomFilter_HasNoFalseNegatives(t *testing.T) {
+	f := newBloomFilter(1000, 0.01)
+
+	for n := uint64(0); n < 1000; n++ {
+		f.add(n * 7919)
+	}
+
+	for n := uint64(0); n < 1000; n++ {
+		assert.True(t, f.mayContain(n*7919))
+	}
+}
+
+func TestBloomFilter_KeepsFalsePositiveRateAtCapacity(t *testing.T) {
+	capacity := 10000
+	f := newBloomFilter(capacity, 0.01)
+
+	for n := 0; n < capacity; n++ {
+		f.add(uint64(n))
+	}
+
+	falsePositives := 0
+	for n := capacity; n < 2*capacity; n++ {
+		if f.mayContain(uint64(n)) {
+			falsePositives++
+		}
+	}
+
+	observed := float64(falsePositives) / float64(capacity)
+	assert.InDelta(t, 0.01, observed, 0.01)
+	assert.InDelta(t, 0.01, f.falsePositiveRate(), 0.002)
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// DiskRepository stores unique numbers on a bitmap file, keeping in memory only the ones not committed yet,
+// so memory use does not grow with the amount of numbers and state survives restarts
+// * checks of numbers not added since last commit read the file, served by the os page cache, making AddNumber
+// bound by syscall cost, about 40 times slower than InMemoryRepository, see BenchmarkDiskRepository_AddNumber
+// * committed numbers are written on the file right after being logged, a crash in between makes them logged again
+type DiskRepository struct {
+	numbers *spilledSet
+	sync.RWMutex
+}
+
+// NewDiskRepository creates a repository stored on given path, keeping the numbers stored if exists
+func NewDiskRepository(path string) (*DiskRepository, error) {
+	disk, err := openBitmapFile(path, os.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskRepository{numbers: newSpilledSet(disk)}, nil
+}
+
+// AddNumber adds a number if unique returning success
+func (r *DiskRepository) AddNumber(number uint32) (unique bool) {
+	// disk reads are done concurrently
+	if r.Contains(number) {
+		return false
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	// added meanwhile, even if already committed to disk
+	if r.numbers.contains(number) {
+		return false
+	}
+
+	r.numbers.add(number)
+
+	return true
+}
+
+// Contains returns true if number was added, either extracted or not
+func (r *DiskRepository) Contains(number uint32) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.numbers.contains(number)
+}
+
+// ExtractTransaction returns unique numbers list delaying data removal to commit
+func (r *DiskRepository) ExtractTransaction() []uint32 {
+	r.Lock()
+
+	return r.numbers.extract()
+}
+
+// Commit writes extracted numbers on disk and unlocks, keeping them in memory if the write fails
+func (r *DiskRepository) Commit() {
+	r.numbers.commit()
+	r.Unlock()
+}
+
+// Rollback unlocks without data removal
+func (r *DiskRepository) Rollback() {
+	r.Unlock()
+}
+
+// Snapshot writes committed numbers, reading them from disk without blocking ingestion
+func (r *DiskRepository) Snapshot(w io.Writer) error {
+	r.RLock()
+	pending := r.numbers.unpersistedNumbers()
+	r.RUnlock()
+
+	return r.numbers.snapshot(w, pending)
+}
+
+// Restore adds snapshot numbers as committed ones
+func (r *DiskRepository) Restore(input io.Reader) error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.numbers.restore(input, func(uint32) {})
+}
+
+// Close closes the repository file
+func (r *DiskRepository) Close() error {
+	return r.numbers.close()
+}
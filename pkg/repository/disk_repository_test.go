@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskRepository_AddNumber(t *testing.T) {
+	r, path := newTestDiskRepository(t)
+	defer r.Close()
+
+	for _, n := range []uint32{11, 22, 33} {
+		assert.True(t, r.AddNumber(n))
+	}
+
+	assert.False(t, r.AddNumber(11), "repeated number should not return unique")
+	assert.FileExists(t, path)
+}
+
+func TestDiskRepository_KeepsCommittedNumbersOnReopen(t *testing.T) {
+	r, path := newTestDiskRepository(t)
+
+	_ = r.AddNumber(11)
+	_ = r.AddNumber(22)
+
+	assert.Len(t, r.ExtractTransaction(), 2)
+	r.Commit()
+
+	// not committed, so not stored
+	_ = r.AddNumber(33)
+	assert.NoError(t, r.Close())
+
+	reopened, err := NewDiskRepository(path)
+	if err != nil {
+		t.Fatalf("cannot reopen disk repository: %s", err.Error())
+	}
+	defer reopened.Close()
+
+	assert.False(t, reopened.AddNumber(11))
+	assert.False(t, reopened.AddNumber(22))
+	assert.True(t, reopened.AddNumber(33))
+	assert.Len(t, reopened.ExtractTransaction(), 1)
+	reopened.Commit()
+}
+
+func TestDiskRepository_AddNumberIsUniqueOnceWhileCommitting(t *testing.T) {
+	r, _ := newTestDiskRepository(t)
+	defer r.Close()
+
+	assertUniqueOnceWhileCommitting(t, r)
+}
+
+func TestDiskRepository_SnapshotDoesNotBlockAdds(t *testing.T) {
+	r, _ := newTestDiskRepository(t)
+	defer r.Close()
+
+	assertSnapshotDoesNotBlockAdds(t, r)
+}
+
+func TestDiskRepository_Rollback(t *testing.T) {
+	r, _ := newTestDiskRepository(t)
+	defer r.Close()
+
+	_ = r.AddNumber(11)
+
+	assert.Len(t, r.ExtractTransaction(), 1)
+	r.Rollback()
+
+	assert.Len(t, r.ExtractTransaction(), 1)
+	r.Commit()
+
+	assert.Len(t, r.ExtractTransaction(), 0)
+	r.Commit()
+
+	assert.True(t, r.Contains(11))
+}
+
+func BenchmarkInMemoryRepository_AddNumber(b *testing.B) {
+	benchmarkAddNumber(b, NewInMemoryRepository())
+}
+
+func BenchmarkDiskRepository_AddNumber(b *testing.B) {
+	dir := b.TempDir()
+
+	r, err := NewDiskRepository(filepath.Join(dir, "numbers.bitmap"))
+	if err != nil {
+		b.Fatalf("cannot create disk repository: %s", err.Error())
+	}
+	defer r.Close()
+
+	benchmarkAddNumber(b, r)
+}
+
+// benchmarkAddNumber adds spread numbers committing on batches, as result runner does
+func benchmarkAddNumber(b *testing.B, r NumberRepository) {
+	for i := 0; i < b.N; i++ {
+		_ = r.AddNumber(uint32(i) * 7919)
+
+		if i%100000 == 0 {
+			_ = r.ExtractTransaction()
+			r.Commit()
+		}
+	}
+}
+
+func newTestDiskRepository(t *testing.T) (r *DiskRepository, path string) {
+	dir := t.TempDir()
+
+	path = filepath.Join(dir, "numbers.bitmap")
+
+	var err error
+	r, err = NewDiskRepository(path)
+	if err != nil {
+		t.Fatalf("cannot create disk repository: %s", err.Error())
+	}
+
+	return r, path
+}
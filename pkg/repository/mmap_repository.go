@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MmapRepository stores unique numbers on a memory-mapped bitmap file, so state is restored instantly on restart
+// * numbers are set on the bitmap on commit, once logged, and the file is synced, a failed sync is returned by
+// PersistErr and retried on next commit
+// * a crash right after numbers are logged and before they are committed makes them logged again on restart
+// * numbers over the max number it was opened for are always unique
+type MmapRepository struct {
+	bitmap       *mmapBitmap
+	nonExtracted map[uint32]struct{}
+	// last commit sync error
+	syncErr error
+	closed  bool
+	sync.RWMutex
+}
+
+// NewMmapRepository creates a repository mapping given path for numbers up to maxNumber, keeping the numbers stored
+// if exists, e.g: 125MB for 9-digit numbers
+func NewMmapRepository(path string, maxNumber uint32) (*MmapRepository, error) {
+	bitmap, err := openMmapBitmap(path, maxNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MmapRepository{
+		bitmap:       bitmap,
+		nonExtracted: make(map[uint32]struct{}),
+	}, nil
+}
+
+// AddNumber adds a number if unique returning success
+func (r *MmapRepository) AddNumber(number uint32) (unique bool) {
+	if r.Contains(number) {
+		return false
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	// added meanwhile, even if already committed to the bitmap
+	_, exists := r.nonExtracted[number]
+	if exists || r.bitmap.fits(number) && r.bitmap.contains(number) {
+		return false
+	}
+
+	r.nonExtracted[number] = struct{}{}
+
+	return true
+}
+
+// Contains returns true if number was added, either extracted or not
+func (r *MmapRepository) Contains(number uint32) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	_, exists := r.nonExtracted[number]
+	if exists {
+		return true
+	}
+
+	return r.bitmap.fits(number) && r.bitmap.contains(number)
+}
+
+// ExtractTransaction returns unique numbers list delaying data removal to commit
+func (r *MmapRepository) ExtractTransaction() (uniques []uint32) {
+	r.Lock()
+	for n := range r.nonExtracted {
+		uniques = append(uniques, n)
+	}
+
+	return
+}
+
+// Commit sets extracted numbers on the bitmap, syncing it, and unlocks
+func (r *MmapRepository) Commit() {
+	for n := range r.nonExtracted {
+		if r.bitmap.fits(n) {
+			r.bitmap.set(n)
+		}
+	}
+	r.nonExtracted = make(map[uint32]struct{})
+
+	// already visible to the os, so only an os crash loses them if sync fails
+	r.syncErr = r.bitmap.sync()
+
+	r.Unlock()
+}
+
+// PersistErr returns the error syncing the bitmap file on last commit, nil if synced
+func (r *MmapRepository) PersistErr() error {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.syncErr
+}
+
+// Rollback unlocks without data removal
+func (r *MmapRepository) Rollback() {
+	r.Unlock()
+}
+
+// Snapshot writes committed numbers, copying the bitmap by chunks so ingestion only waits for a chunk copy,
+// numbers committed meanwhile may be missing
+func (r *MmapRepository) Snapshot(w io.Writer) error {
+	chunk := make([]byte, bitmapFileChunk)
+
+	return writeSnapshot(w, func(add func(uint32) error) error {
+		for offset := 0; ; offset += len(chunk) {
+			r.RLock()
+			if r.closed {
+				r.RUnlock()
+				return errors.New("repository closed while snapshotting")
+			}
+			if offset >= len(r.bitmap.bits) {
+				r.RUnlock()
+				return nil
+			}
+			copied := copy(chunk, r.bitmap.bits[offset:])
+			r.RUnlock()
+
+			err := eachBit(chunk[:copied], uint64(offset)*8, add)
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Restore adds snapshot numbers as committed ones, numbers over max number are ignored
+func (r *MmapRepository) Restore(input io.Reader) error {
+	r.Lock()
+	defer r.Unlock()
+
+	err := readSnapshot(input, func(number uint32) error {
+		delete(r.nonExtracted, number)
+		if r.bitmap.fits(number) {
+			r.bitmap.set(number)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.bitmap.sync()
+}
+
+// Close unmaps the bitmap file
+func (r *MmapRepository) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.closed = true
+
+	return r.bitmap.close()
+}
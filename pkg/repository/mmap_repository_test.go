@@ -0,0 +1,147 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMmapRepository_AddNumber(t *testing.T) {
+	r, _ := newTestMmapRepository(t, 999)
+	defer r.Close()
+
+	for _, n := range []uint32{0, 11, 999} {
+		assert.True(t, r.AddNumber(n))
+	}
+
+	assert.False(t, r.AddNumber(11), "repeated number should not return unique")
+
+	_ = r.ExtractTransaction()
+	r.Commit()
+
+	assert.False(t, r.AddNumber(999), "committed number should not return unique")
+	assert.True(t, r.Contains(0))
+}
+
+func TestMmapRepository_RestoresCommittedNumbersOnReopen(t *testing.T) {
+	r, path := newTestMmapRepository(t, 999999999)
+
+	_ = r.AddNumber(11)
+	_ = r.AddNumber(999999999)
+
+	assert.Len(t, r.ExtractTransaction(), 2)
+	r.Commit()
+
+	// not committed, so not stored
+	_ = r.AddNumber(33)
+	assert.NoError(t, r.Close())
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(125000000), info.Size())
+
+	reopened, err := NewMmapRepository(path, 999999999)
+	if err != nil {
+		t.Fatalf("cannot reopen mmap repository: %s", err.Error())
+	}
+	defer reopened.Close()
+
+	assert.False(t, reopened.AddNumber(11))
+	assert.False(t, reopened.AddNumber(999999999))
+	assert.True(t, reopened.AddNumber(33))
+}
+
+func TestMmapRepository_KeepsNumbersOnReopenForSmallerMax(t *testing.T) {
+	r, path := newTestMmapRepository(t, 99999)
+
+	_ = r.AddNumber(99999)
+	assert.Len(t, r.ExtractTransaction(), 1)
+	r.Commit()
+	assert.NoError(t, r.PersistErr())
+	assert.NoError(t, r.Close())
+
+	reopened, err := NewMmapRepository(path, 999)
+	if err != nil {
+		t.Fatalf("cannot reopen mmap repository: %s", err.Error())
+	}
+
+	assert.False(t, reopened.AddNumber(99999), "numbers over the new max should be kept")
+	assert.NoError(t, reopened.Close())
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12500), info.Size(), "file should not shrink")
+}
+
+func TestMmapRepository_AddNumberIsUniqueOnceWhileCommitting(t *testing.T) {
+	r, _ := newTestMmapRepository(t, 999999)
+	defer r.Close()
+
+	assertUniqueOnceWhileCommitting(t, r)
+}
+
+func TestMmapRepository_SnapshotDoesNotBlockAdds(t *testing.T) {
+	r, _ := newTestMmapRepository(t, 999)
+	defer r.Close()
+
+	assertSnapshotDoesNotBlockAdds(t, r)
+}
+
+func TestMmapRepository_Rollback(t *testing.T) {
+	r, _ := newTestMmapRepository(t, 999)
+	defer r.Close()
+
+	_ = r.AddNumber(11)
+
+	assert.Len(t, r.ExtractTransaction(), 1)
+	r.Rollback()
+
+	assert.Len(t, r.ExtractTransaction(), 1)
+	r.Commit()
+
+	assert.Len(t, r.ExtractTransaction(), 0)
+	r.Commit()
+}
+
+func TestMmapRepository_HandlesNumbersOverMaxAsUnique(t *testing.T) {
+	r, _ := newTestMmapRepository(t, 999)
+	defer r.Close()
+
+	assert.True(t, r.AddNumber(1000))
+	assert.False(t, r.AddNumber(1000), "not committed number should be duplicate")
+
+	_ = r.ExtractTransaction()
+	r.Commit()
+
+	assert.True(t, r.AddNumber(1000))
+}
+
+func BenchmarkMmapRepository_AddNumber(b *testing.B) {
+	dir := b.TempDir()
+
+	r, err := NewMmapRepository(filepath.Join(dir, "numbers.bitmap"), 1<<32-1)
+	if err != nil {
+		b.Fatalf("cannot create mmap repository: %s", err.Error())
+	}
+	defer r.Close()
+
+	benchmarkAddNumber(b, r)
+}
+
+func newTestMmapRepository(t *testing.T, maxNumber uint32) (r *MmapRepository, path string) {
+	dir := t.TempDir()
+
+	path = filepath.Join(dir, "numbers.bitmap")
+
+	var err error
+	r, err = NewMmapRepository(path, maxNumber)
+	if err != nil {
+		t.Fatalf("cannot create mmap repository: %s", err.Error())
+	}
+
+	return r, path
+}
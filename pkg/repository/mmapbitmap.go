@@ -0,0 +1,94 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package repository
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// mmapBitmap bitmap file mapped in memory, a bit per number from 0 to its capacity
+// reads and writes are memory accesses, written pages are persisted by the os or on sync
+type mmapBitmap struct {
+	fd   *os.File
+	bits []byte
+}
+
+// openMmapBitmap maps a bitmap file fitting numbers up to maxNumber, keeping its content if exists
+func openMmapBitmap(path string, maxNumber uint32) (*mmapBitmap, error) {
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open bitmap file")
+	}
+
+	size := int(uint64(maxNumber)/8 + 1)
+
+	stat, err := fd.Stat()
+	if err != nil {
+		_ = fd.Close()
+		return nil, errors.Wrap(err, "cannot stat bitmap file")
+	}
+
+	// a file opened before for bigger numbers is mapped whole, as shrinking it would lose them
+	if stat.Size() > int64(size) {
+		size = int(stat.Size())
+	}
+
+	// growing an existing file keeps its content
+	if stat.Size() < int64(size) {
+		err = fd.Truncate(int64(size))
+		if err != nil {
+			_ = fd.Close()
+			return nil, errors.Wrap(err, "cannot size bitmap file")
+		}
+	}
+
+	bits, err := syscall.Mmap(int(fd.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		_ = fd.Close()
+		return nil, errors.Wrap(err, "cannot map bitmap file")
+	}
+
+	return &mmapBitmap{fd: fd, bits: bits}, nil
+}
+
+// fits returns true if number is within bitmap capacity
+func (b *mmapBitmap) fits(number uint32) bool {
+	return int(number/8) < len(b.bits)
+}
+
+func (b *mmapBitmap) contains(number uint32) bool {
+	return b.bits[number/8]&(1<<(number%8)) != 0
+}
+
+func (b *mmapBitmap) set(number uint32) {
+	b.bits[number/8] |= 1 << (number % 8)
+}
+
+// sync blocks until written pages are persisted
+func (b *mmapBitmap) sync() error {
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_MSYNC,
+		uintptr(unsafe.Pointer(&b.bits[0])),
+		uintptr(len(b.bits)),
+		syscall.MS_SYNC,
+	)
+	if errno != 0 {
+		return errors.Wrap(errno, "cannot sync bitmap file")
+	}
+
+	return nil
+}
+
+func (b *mmapBitmap) close() error {
+	err := syscall.Munmap(b.bits)
+	if err != nil {
+		_ = b.fd.Close()
+		return errors.Wrap(err, "cannot unmap bitmap file")
+	}
+
+	return b.fd.Close()
+}
@@ -0,0 +1,34 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package repository
+
+import (
+	"github.com/pkg/errors"
+)
+
+// mmapBitmap unsupported on this platform, the mmap repository cannot be opened
+type mmapBitmap struct {
+	bits []byte
+}
+
+func openMmapBitmap(path string, maxNumber uint32) (*mmapBitmap, error) {
+	return nil, errors.New("mmap repository is not supported on this platform")
+}
+
+func (b *mmapBitmap) fits(number uint32) bool {
+	return int(number/8) < len(b.bits)
+}
+
+func (b *mmapBitmap) contains(number uint32) bool {
+	return false
+}
+
+func (b *mmapBitmap) set(number uint32) {}
+
+func (b *mmapBitmap) sync() error {
+	return nil
+}
+
+func (b *mmapBitmap) close() error {
+	return nil
+}
@@ -0,0 +1,42 @@
+package repository
+
+// NumberSet adds and looks up numbers regardless of the repository width
+// so ingestion paths are shared by 32 and 64-bit repositories
+type NumberSet interface {
+	Add(number uint64) (unique bool)
+	Contains(number uint64) bool
+}
+
+// NewNumberSet wraps a 32-bit repository, numbers added must fit on 32 bits
+func NewNumberSet(r NumberRepository) NumberSet {
+	return numberSet32{r}
+}
+
+// NewNumberSet64 wraps a 64-bit repository
+func NewNumberSet64(r NumberRepository64) NumberSet {
+	return numberSet64{r}
+}
+
+type numberSet32 struct {
+	repo NumberRepository
+}
+
+func (s numberSet32) Add(number uint64) bool {
+	return s.repo.AddNumber(uint32(number))
+}
+
+func (s numberSet32) Contains(number uint64) bool {
+	return s.repo.Contains(uint32(number))
+}
+
+type numberSet64 struct {
+	repo NumberRepository64
+}
+
+func (s numberSet64) Add(number uint64) bool {
+	return s.repo.AddNumber(number)
+}
+
+func (s numberSet64) Contains(number uint64) bool {
+	return s.repo.Contains(number)
+}
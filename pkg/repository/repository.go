@@ -1,16 +1,40 @@
 package repository
 
-import "sync"
+import (
+	"io"
+	"sort"
+	"sync"
+)
 
 // NumberRepository stores unique numbers, extracting in a 2-phase-commit manner to enable transactional support
-// * uniqueness is guaranteed against all numbers added
+// * uniqueness is guaranteed against all numbers added, but a number whose presence cannot be checked, e.g. over
+// capacity or on a failed disk read, is handled as unique, as logging a duplicate is better than losing a number
 // * ExtractTransaction pulls out only the unique numbers added since the last ExtractTransaction call
+// * Snapshot writes the committed numbers, the ones already extracted, which Restore adds as committed ones
 type NumberRepository interface {
 	AddNumber(number uint32) (unique bool)
+	// Contains returns true if number was added, without adding it
+	Contains(number uint32) bool
 	// 2PC extract methods:
 	ExtractTransaction() []uint32
 	Commit()
 	Rollback()
+	// snapshot methods, in a versioned binary format shared by all repositories:
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
+// ResettableRepository repository able to forget all its numbers, so dedupe scope restarts
+type ResettableRepository interface {
+	NumberRepository
+	// CommitAndReset ends the transaction forgetting all numbers, the extracted ones included
+	CommitAndReset()
+}
+
+// PersistentRepository repository persisting committed numbers, which may fail once they are already committed
+type PersistentRepository interface {
+	// PersistErr returns the error persisting the last commit, nil if persisted
+	PersistErr() error
 }
 
 // InMemoryRepository stores unique numbers in memory with concurrency support
@@ -51,6 +75,19 @@ func (r *InMemoryRepository) AddNumber(number uint32) (unique bool) {
 	return true
 }
 
+// Contains returns true if number was added, either extracted or not
+func (r *InMemoryRepository) Contains(number uint32) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	_, exists := r.uniques[number]
+	if !exists {
+		_, exists = r.nonExtracted[number]
+	}
+
+	return exists
+}
+
 // ExtractTransaction returns unique numbers list delaying data removal to commit
 func (r *InMemoryRepository) ExtractTransaction() (uniques []uint32) {
 	r.Lock()
@@ -72,7 +109,50 @@ func (r *InMemoryRepository) Commit() {
 	r.Unlock()
 }
 
+// CommitAndReset unlocks forgetting the stored numbers, the extracted ones included
+func (r *InMemoryRepository) CommitAndReset() {
+	r.uniques = make(map[uint32]struct{})
+	r.nonExtracted = make(map[uint32]struct{})
+	r.Unlock()
+}
+
 // Rollback unlocks without data removal
 func (r *InMemoryRepository) Rollback() {
 	r.Unlock()
 }
+
+// Snapshot writes committed numbers
+func (r *InMemoryRepository) Snapshot(w io.Writer) error {
+	r.RLock()
+	numbers := make([]uint32, 0, len(r.uniques))
+	for n := range r.uniques {
+		numbers = append(numbers, n)
+	}
+	r.RUnlock()
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	return writeSnapshot(w, func(add func(uint32) error) error {
+		for _, n := range numbers {
+			err := add(n)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Restore adds snapshot numbers as committed ones
+func (r *InMemoryRepository) Restore(input io.Reader) error {
+	r.Lock()
+	defer r.Unlock()
+
+	return readSnapshot(input, func(number uint32) error {
+		delete(r.nonExtracted, number)
+		r.uniques[number] = struct{}{}
+
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package repository
+
+import "sync"
+
+// NumberRepository64 is the NumberRepository variant for numbers up to 64 bits
+// numbers fitting on 32 bits should use NumberRepository, as it takes half the memory
+type NumberRepository64 interface {
+	AddNumber(number uint64) (unique bool)
+	// Contains returns true if number was added, without adding it
+	Contains(number uint64) bool
+	// 2PC extract methods:
+	ExtractTransaction() []uint64
+	Commit()
+	Rollback()
+}
+
+// InMemoryRepository64 stores unique 64-bit numbers in memory with concurrency support
+type InMemoryRepository64 struct {
+	// keeps in memory list of numbers added
+	uniques      map[uint64]struct{} // faster access than list
+	nonExtracted map[uint64]struct{}
+	sync.RWMutex
+}
+
+// NewInMemoryRepository64 stores 64-bit numbers in memory
+func NewInMemoryRepository64() NumberRepository64 {
+	return &InMemoryRepository64{
+		uniques:      make(map[uint64]struct{}),
+		nonExtracted: make(map[uint64]struct{}),
+	}
+}
+
+// AddNumber adds a number if unique returning success
+func (r *InMemoryRepository64) AddNumber(number uint64) (unique bool) {
+	r.RLock()
+	_, exists := r.uniques[number]
+	if exists {
+		r.RUnlock()
+		return false
+	}
+	_, exists = r.nonExtracted[number]
+	r.RUnlock()
+
+	if exists {
+		return false
+	}
+
+	r.Lock()
+	r.nonExtracted[number] = struct{}{}
+	r.Unlock()
+
+	return true
+}
+
+// Contains returns true if number was added, either extracted or not
+func (r *InMemoryRepository64) Contains(number uint64) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	_, exists := r.uniques[number]
+	if !exists {
+		_, exists = r.nonExtracted[number]
+	}
+
+	return exists
+}
+
+// ExtractTransaction returns unique numbers list delaying data removal to commit
+func (r *InMemoryRepository64) ExtractTransaction() (uniques []uint64) {
+	r.Lock()
+	for n := range r.nonExtracted {
+		uniques = append(uniques, n)
+	}
+
+	return
+}
+
+// Commit unlocks emptying the stored numbers
+func (r *InMemoryRepository64) Commit() {
+	for n := range r.nonExtracted {
+		r.uniques[n] = struct{}{}
+	}
+
+	r.nonExtracted = make(map[uint64]struct{})
+	r.Unlock()
+}
+
+// Rollback unlocks without data removal
+func (r *InMemoryRepository64) Rollback() {
+	r.Unlock()
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryRepository64_AddNumber(t *testing.T) {
+	uniqueNumbers := []uint64{11, 1 << 40, 9999999999999999999}
+
+	r := NewInMemoryRepository64()
+
+	for _, n := range uniqueNumbers {
+		assert.True(t, r.AddNumber(n))
+	}
+
+	assert.False(t, r.AddNumber(1<<40), "repeated number should not return unique")
+	assert.True(t, r.Contains(9999999999999999999))
+	assert.False(t, r.Contains(12))
+}
+
+func TestInMemoryRepository64_ExtractTransaction(t *testing.T) {
+	r := NewInMemoryRepository64()
+
+	_ = r.AddNumber(1 << 40)
+
+	result := r.ExtractTransaction()
+	r.Rollback()
+
+	assert.Equal(t, []uint64{1 << 40}, result)
+
+	result = r.ExtractTransaction()
+	r.Commit()
+
+	assert.Len(t, result, 1)
+
+	_ = r.AddNumber(1 << 40)
+
+	assert.Len(t, r.ExtractTransaction(), 0)
+	r.Commit()
+	assert.True(t, r.Contains(1<<40), "committed numbers should be contained")
+}
+
+func TestNumberSet_WrapsRepositories(t *testing.T) {
+	sets := []NumberSet{
+		NewNumberSet(NewInMemoryRepository()),
+		NewNumberSet64(NewInMemoryRepository64()),
+	}
+
+	for _, s := range sets {
+		assert.True(t, s.Add(314159265))
+		assert.False(t, s.Add(314159265))
+		assert.True(t, s.Contains(314159265))
+	}
+}
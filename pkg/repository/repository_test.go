@@ -1,9 +1,9 @@
 package repository
 
 import (
-	"testing"
-
 	"sync"
+	"sync/atomic"
+	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -117,9 +117,81 @@ func addNumbers(r NumberRepository, numbersA []uint32, ready chan struct{}, wg *
 	wg.Done()
 }
 
+// assertUniqueOnceWhileCommitting adds the same numbers concurrently while they are committed,
+// each number should be unique only once
+func assertUniqueOnceWhileCommitting(t *testing.T, r NumberRepository) {
+	numbers := makeRange(1, 2000)
+	uniques := int64(0)
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer wg.Done()
+			for _, n := range numbers {
+				if r.AddNumber(n) {
+					atomic.AddInt64(&uniques, 1)
+				}
+			}
+		}()
+	}
+
+	added := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(added)
+	}()
+
+	committed := 0
+	for done := false; !done; {
+		select {
+		case <-added:
+			done = true
+		default:
+		}
+
+		committed += len(r.ExtractTransaction())
+		r.Commit()
+	}
+
+	assert.Equal(t, int64(len(numbers)), atomic.LoadInt64(&uniques))
+	assert.Equal(t, len(numbers), committed)
+}
+
 func makeRange(min, max uint32) (list []uint32) {
 	for i := min; i <= max; i++ {
 		list = append(list, i)
 	}
 	return
 }
+
+func TestInMemoryRepository_Contains(t *testing.T) {
+	r := NewInMemoryRepository()
+
+	_ = r.AddNumber(11)
+	_ = r.ExtractTransaction()
+	r.Commit()
+
+	_ = r.AddNumber(22)
+
+	assert.True(t, r.Contains(11), "extracted numbers should be contained")
+	assert.True(t, r.Contains(22), "non extracted numbers should be contained")
+	assert.False(t, r.Contains(33))
+
+	assert.True(t, r.AddNumber(33), "contains should not add numbers")
+}
+
+func TestInMemoryRepository_CommitAndReset(t *testing.T) {
+	r := NewInMemoryRepository().(*InMemoryRepository)
+
+	_ = r.AddNumber(11)
+
+	result := r.ExtractTransaction()
+	r.CommitAndReset()
+
+	assert.Equal(t, []uint32{11}, result)
+	assert.False(t, r.Contains(11))
+	assert.True(t, r.AddNumber(11), "number should be unique again after reset")
+	assert.Equal(t, []uint32{11}, r.ExtractTransaction())
+	r.Commit()
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// Snapshot binary format, little endian, roaring-style: numbers are grouped on containers by their high 16 bits
+// * header: magic "NUMS" and version byte
+// * containers in ascending key order: kind byte, key uint16 and the numbers low 16 bits as array or bitmap
+// * array container: count uint16 and count ascending uint16, used when up to arrayContainerMax numbers
+// * bitmap container: 8KiB bitmap, a bit per low 16 bits value
+// * end kind byte and crc32 (IEEE) of all previous bytes
+const (
+	snapshotMagic   = "NUMS"
+	snapshotVersion = 1
+
+	containerEnd    = 0
+	containerArray  = 1
+	containerBitmap = 2
+
+	arrayContainerMax = 4096
+	bitmapContainer   = 1 << 16 / 8
+)
+
+// ErrSnapshotCorrupted returned on restore when snapshot checksum does not match
+var ErrSnapshotCorrupted = errors.New("snapshot corrupted")
+
+// snapshotEncoder writes numbers added in ascending order as a snapshot
+type snapshotEncoder struct {
+	output *bufio.Writer
+	crc    hash.Hash32
+	w      io.Writer
+	key    uint16
+	values []uint16
+}
+
+func newSnapshotEncoder(w io.Writer) (*snapshotEncoder, error) {
+	output := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+
+	e := &snapshotEncoder{
+		output: output,
+		crc:    crc,
+		w:      io.MultiWriter(output, crc),
+	}
+
+	_, err := e.w.Write(append([]byte(snapshotMagic), snapshotVersion))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot write snapshot header")
+	}
+
+	return e, nil
+}
+
+// add adds a number, greater than the previous one
+func (e *snapshotEncoder) add(number uint32) error {
+	key := uint16(number >> 16)
+
+	if key != e.key && len(e.values) > 0 {
+		err := e.writeContainer()
+		if err != nil {
+			return err
+		}
+	}
+
+	e.key = key
+	e.values = append(e.values, uint16(number))
+
+	return nil
+}
+
+func (e *snapshotEncoder) writeContainer() error {
+	var buf bytes.Buffer
+
+	if len(e.values) <= arrayContainerMax {
+		buf.WriteByte(containerArray)
+		_ = binary.Write(&buf, binary.LittleEndian, e.key)
+		_ = binary.Write(&buf, binary.LittleEndian, uint16(len(e.values)))
+		_ = binary.Write(&buf, binary.LittleEndian, e.values)
+	} else {
+		bitmap := make([]byte, bitmapContainer)
+		for _, v := range e.values {
+			bitmap[v/8] |= 1 << (v % 8)
+		}
+
+		buf.WriteByte(containerBitmap)
+		_ = binary.Write(&buf, binary.LittleEndian, e.key)
+		buf.Write(bitmap)
+	}
+
+	e.values = e.values[:0]
+
+	_, err := e.w.Write(buf.Bytes())
+
+	return errors.Wrap(err, "cannot write snapshot container")
+}
+
+// close writes pending numbers and snapshot end
+func (e *snapshotEncoder) close() error {
+	if len(e.values) > 0 {
+		err := e.writeContainer()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := e.w.Write([]byte{containerEnd})
+	if err != nil {
+		return errors.Wrap(err, "cannot write snapshot end")
+	}
+
+	err = binary.Write(e.output, binary.LittleEndian, e.crc.Sum32())
+	if err != nil {
+		return errors.Wrap(err, "cannot write snapshot checksum")
+	}
+
+	return errors.Wrap(e.output.Flush(), "cannot write snapshot")
+}
+
+// writeSnapshot writes a snapshot of the numbers given in ascending order by each
+func writeSnapshot(w io.Writer, each func(add func(uint32) error) error) error {
+	encoder, err := newSnapshotEncoder(w)
+	if err != nil {
+		return err
+	}
+
+	err = each(encoder.add)
+	if err != nil {
+		return err
+	}
+
+	return encoder.close()
+}
+
+// snapshotContainer numbers sharing the high 16 bits, either on values or bitmap
+type snapshotContainer struct {
+	key    uint16
+	values []uint16
+	bitmap []byte
+}
+
+// readSnapshot reads a whole snapshot validating it before calling add with each number in ascending order,
+// so a corrupted snapshot is not partially restored
+func readSnapshot(r io.Reader, add func(uint32) error) error {
+	crc := crc32.NewIEEE()
+	input := io.TeeReader(bufio.NewReader(r), crc)
+
+	header := make([]byte, len(snapshotMagic)+1)
+	_, err := io.ReadFull(input, header)
+	if err != nil {
+		return errors.Wrap(err, "cannot read snapshot header")
+	}
+
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return errors.New("not a snapshot")
+	}
+
+	if header[len(snapshotMagic)] != snapshotVersion {
+		return errors.Errorf("unsupported snapshot version %d", header[len(snapshotMagic)])
+	}
+
+	var containers []snapshotContainer
+	for {
+		var kind [1]byte
+		_, err = io.ReadFull(input, kind[:])
+		if err != nil {
+			return errors.Wrap(err, "cannot read snapshot container")
+		}
+
+		if kind[0] == containerEnd {
+			break
+		}
+
+		container, err := readContainer(input, kind[0])
+		if err != nil {
+			return err
+		}
+		containers = append(containers, container)
+	}
+
+	expected := crc.Sum32()
+
+	var checksum uint32
+	err = binary.Read(input, binary.LittleEndian, &checksum)
+	if err != nil {
+		return errors.Wrap(err, "cannot read snapshot checksum")
+	}
+
+	if checksum != expected {
+		return ErrSnapshotCorrupted
+	}
+
+	for _, c := range containers {
+		high := uint32(c.key) << 16
+
+		for _, v := range c.values {
+			err = add(high | uint32(v))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = eachBit(c.bitmap, uint64(high), add)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readContainer(input io.Reader, kind byte) (c snapshotContainer, err error) {
+	err = binary.Read(input, binary.LittleEndian, &c.key)
+	if err != nil {
+		return c, errors.Wrap(err, "cannot read snapshot container key")
+	}
+
+	switch kind {
+	case containerArray:
+		var count uint16
+		err = binary.Read(input, binary.LittleEndian, &count)
+		if err != nil {
+			return c, errors.Wrap(err, "cannot read snapshot container size")
+		}
+
+		c.values = make([]uint16, count)
+		err = binary.Read(input, binary.LittleEndian, c.values)
+
+	case containerBitmap:
+		c.bitmap = make([]byte, bitmapContainer)
+		_, err = io.ReadFull(input, c.bitmap)
+
+	default:
+		return c, errors.Errorf("unknown snapshot container kind %d", kind)
+	}
+
+	return c, errors.Wrap(err, "cannot read snapshot container")
+}
+
+// empty bitmap block, skipped at once while looking for numbers set
+var emptyBlock [4096]byte
+
+// eachBit calls add with each number set on given bitmap chunk starting at number offset, in ascending order
+func eachBit(chunk []byte, offset uint64, add func(uint32) error) error {
+	for start := 0; start < len(chunk); start += len(emptyBlock) {
+		block := chunk[start:]
+		if len(block) > len(emptyBlock) {
+			block = block[:len(emptyBlock)]
+		}
+
+		if bytes.Equal(block, emptyBlock[:len(block)]) {
+			continue
+		}
+
+		for i, b := range block {
+			for ; b != 0; b &= b - 1 {
+				err := add(uint32(offset + uint64(start+i)*8 + uint64(bits.TrailingZeros8(b))))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sparse and dense numbers, so both container kinds are used
+func snapshotNumbers() (numbers []uint32) {
+	numbers = append(numbers, 0, 7, 65535, 65536, 4294967295)
+	for n := uint32(1 << 20); n < 1<<20+10000; n++ {
+		numbers = append(numbers, n)
+	}
+
+	return
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	numbers := snapshotNumbers()
+
+	var buf bytes.Buffer
+	err := writeSnapshot(&buf, func(add func(uint32) error) error {
+		for _, n := range numbers {
+			assert.NoError(t, add(n))
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	var restored []uint32
+	err = readSnapshot(&buf, func(n uint32) error {
+		restored = append(restored, n)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, numbers, restored)
+}
+
+func TestSnapshot_IsCompact(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeSnapshot(&buf, func(add func(uint32) error) error {
+		for n := uint32(0); n < 1<<16; n++ {
+			_ = add(n)
+		}
+		return add(1 << 24)
+	})
+	assert.NoError(t, err)
+
+	// header, bitmap container, array container, end and checksum
+	assert.Equal(t, 5+(3+bitmapContainer)+(5+2)+1+4, buf.Len())
+}
+
+func TestSnapshot_FailsOnCorruption(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeSnapshot(&buf, func(add func(uint32) error) error {
+		return add(11)
+	})
+	assert.NoError(t, err)
+
+	snapshot := buf.Bytes()
+	snapshot[len(snapshot)-6] ^= 1
+
+	restored := 0
+	err = readSnapshot(bytes.NewReader(snapshot), func(uint32) error {
+		restored++
+		return nil
+	})
+
+	assert.Equal(t, ErrSnapshotCorrupted, err)
+	assert.Equal(t, 0, restored, "corrupted snapshot should not be partially restored")
+}
+
+func TestSnapshot_FailsOnUnknownVersion(t *testing.T) {
+	err := readSnapshot(bytes.NewReader([]byte("NUMS\x09")), func(uint32) error { return nil })
+
+	assert.Error(t, err)
+}
+
+func TestRepositories_SnapshotIsRestoredOnAnyRepository(t *testing.T) {
+	dir := t.TempDir()
+
+	bloom, err := NewBloomRepository(1000, 0.01, filepath.Join(dir, "bloom.bitmap"))
+	assert.NoError(t, err)
+	defer bloom.Close()
+
+	disk, err := NewDiskRepository(filepath.Join(dir, "disk.bitmap"))
+	assert.NoError(t, err)
+	defer disk.Close()
+
+	mmap, err := NewMmapRepository(filepath.Join(dir, "mmap.bitmap"), 1<<24)
+	assert.NoError(t, err)
+	defer mmap.Close()
+
+	repositories := map[string]NumberRepository{
+		"memory": NewInMemoryRepository(),
+		"bloom":  bloom,
+		"disk":   disk,
+		"mmap":   mmap,
+	}
+
+	committed := []uint32{1 << 24, 11, 65536}
+
+	for name, source := range repositories {
+		for _, n := range committed {
+			_ = source.AddNumber(n)
+		}
+		_ = source.ExtractTransaction()
+		source.Commit()
+
+		// not committed, so not on snapshot
+		_ = source.AddNumber(22)
+
+		var snapshot bytes.Buffer
+		assert.NoError(t, source.Snapshot(&snapshot), name)
+
+		restored := NewInMemoryRepository()
+		assert.NoError(t, restored.Restore(bytes.NewReader(snapshot.Bytes())), name)
+
+		for _, n := range committed {
+			assert.False(t, restored.AddNumber(n), "%s snapshot should contain %d", name, n)
+		}
+		assert.True(t, restored.AddNumber(22), "%s snapshot should not contain uncommitted numbers", name)
+
+		for target, r := range repositories {
+			assert.NoError(t, r.Restore(bytes.NewReader(snapshot.Bytes())), target)
+			for _, n := range committed {
+				assert.True(t, r.Contains(n), "%s should contain %d restored from %s", target, n, name)
+			}
+		}
+	}
+}
+
+// assertSnapshotDoesNotBlockAdds snapshots to a blocked writer, numbers should be added meanwhile
+func assertSnapshotDoesNotBlockAdds(t *testing.T, r NumberRepository) {
+	output := &blockedWriter{writing: make(chan struct{}), release: make(chan struct{})}
+
+	snapshotted := make(chan error)
+	go func() {
+		snapshotted <- r.Snapshot(output)
+	}()
+	<-output.writing
+
+	added := make(chan bool)
+	go func() {
+		added <- r.AddNumber(7)
+	}()
+
+	select {
+	case unique := <-added:
+		assert.True(t, unique)
+	case <-time.After(time.Second):
+		t.Error("add should not wait for snapshot")
+		close(output.release)
+		<-added
+		<-snapshotted
+		return
+	}
+
+	close(output.release)
+	assert.NoError(t, <-snapshotted)
+}
+
+// blockedWriter blocks writes until released
+type blockedWriter struct {
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+
+	return len(p), nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"io"
+	"sort"
+)
+
+// spilledSet set of 32-bit numbers kept on a bitmap file, numbers not written on disk yet are kept in memory,
+// it is not concurrency safe
+// * if a disk read fails the number is handled as not contained
+type spilledSet struct {
+	disk *bitmapFile
+	// numbers added since last extraction
+	nonExtracted map[uint32]struct{}
+	// numbers committed but not written on disk yet due to write errors
+	unpersisted map[uint32]struct{}
+}
+
+func newSpilledSet(disk *bitmapFile) *spilledSet {
+	return &spilledSet{
+		disk:         disk,
+		nonExtracted: make(map[uint32]struct{}),
+		unpersisted:  make(map[uint32]struct{}),
+	}
+}
+
+func (s *spilledSet) add(number uint32) {
+	s.nonExtracted[number] = struct{}{}
+}
+
+func (s *spilledSet) contains(number uint32) bool {
+	if s.inMemory(number) {
+		return true
+	}
+
+	exists, _ := s.disk.contains(number)
+
+	return exists
+}
+
+// inMemory returns true if number is not on disk yet
+func (s *spilledSet) inMemory(number uint32) bool {
+	_, exists := s.nonExtracted[number]
+	if !exists {
+		_, exists = s.unpersisted[number]
+	}
+
+	return exists
+}
+
+// extract returns numbers added since last commit
+func (s *spilledSet) extract() (numbers []uint32) {
+	for n := range s.nonExtracted {
+		numbers = append(numbers, n)
+	}
+
+	return
+}
+
+// commit writes extracted numbers on disk, keeping them in memory if the write fails
+func (s *spilledSet) commit() {
+	for n := range s.nonExtracted {
+		s.unpersisted[n] = struct{}{}
+	}
+	s.nonExtracted = make(map[uint32]struct{})
+
+	err := s.disk.set(s.unpersisted)
+	if err == nil {
+		s.unpersisted = make(map[uint32]struct{})
+	}
+}
+
+// unpersistedNumbers returns the committed numbers not written on disk yet, sorted, to be merged on snapshots
+func (s *spilledSet) unpersistedNumbers() (pending []uint32) {
+	for n := range s.unpersisted {
+		pending = append(pending, n)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i] < pending[j] })
+
+	return pending
+}
+
+// snapshot writes committed numbers, merging the sorted ones not written on disk yet
+// it only reads the disk, so it can run while numbers are added and committed: they may be included or not,
+// but none committed before is missed, as numbers are never removed from disk
+func (s *spilledSet) snapshot(w io.Writer, pending []uint32) error {
+	return writeSnapshot(w, func(add func(uint32) error) error {
+		err := s.disk.each(func(number uint32) error {
+			for len(pending) > 0 && pending[0] <= number {
+				if pending[0] < number {
+					err := add(pending[0])
+					if err != nil {
+						return err
+					}
+				}
+				pending = pending[1:]
+			}
+
+			return add(number)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, n := range pending {
+			err = add(n)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// restore sets snapshot numbers on disk as committed, calling restored with each one
+func (s *spilledSet) restore(r io.Reader, restored func(uint32)) error {
+	return s.disk.setAscending(func(set func(uint32) error) error {
+		return readSnapshot(r, func(number uint32) error {
+			delete(s.nonExtracted, number)
+			restored(number)
+
+			return set(number)
+		})
+	})
+}
+
+func (s *spilledSet) close() error {
+	return s.disk.close()
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TTLRepository stores unique numbers while seen within a time window, so a number not seen for longer than its ttl
+// is unique again, on rolling window buckets to avoid tracking a timestamp per number
+// * seeing a number again, even as duplicate, moves it to the current bucket, refreshing its last seen time
+// * numbers expire between ttl and ttl plus a bucket after last seen, as whole buckets expire at once
+// * snapshots do not keep when numbers were seen, restored numbers are handled as just seen
+type TTLRepository struct {
+	bucketDuration time.Duration
+	// ring of numbers seen per bucket, current one at epoch
+	buckets []map[uint32]struct{}
+	epoch   int64
+	// numbers added since last extraction
+	nonExtracted map[uint32]struct{}
+	// expired numbers not returned by Expire yet
+	expired int
+	now     func() time.Time
+	sync.Mutex
+}
+
+// NewTTLRepository creates a repository forgetting numbers not seen for ttl, using given amount of buckets
+func NewTTLRepository(ttl time.Duration, buckets int) (*TTLRepository, error) {
+	return newTTLRepository(ttl, buckets, time.Now)
+}
+
+func newTTLRepository(ttl time.Duration, buckets int, now func() time.Time) (*TTLRepository, error) {
+	if buckets <= 0 {
+		return nil, fmt.Errorf("ttl buckets must be positive, got %d", buckets)
+	}
+
+	if ttl < time.Duration(buckets) {
+		return nil, fmt.Errorf("ttl %s is too short for %d buckets", ttl, buckets)
+	}
+
+	r := &TTLRepository{
+		bucketDuration: ttl / time.Duration(buckets),
+		// a bucket more so numbers are kept at least ttl
+		buckets:      make([]map[uint32]struct{}, buckets+1),
+		nonExtracted: make(map[uint32]struct{}),
+		now:          now,
+	}
+
+	for i := range r.buckets {
+		r.buckets[i] = make(map[uint32]struct{})
+	}
+	r.epoch = r.currentEpoch()
+
+	return r, nil
+}
+
+// AddNumber adds a number if not seen within ttl returning success
+func (r *TTLRepository) AddNumber(number uint32) (unique bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.rotate()
+
+	current := r.bucket(r.epoch)
+
+	seen := r.find(number)
+	if seen != nil {
+		// refresh last seen
+		delete(seen, number)
+		current[number] = struct{}{}
+
+		return false
+	}
+
+	current[number] = struct{}{}
+	r.nonExtracted[number] = struct{}{}
+
+	return true
+}
+
+// Contains returns true if number was seen within ttl, without refreshing it
+func (r *TTLRepository) Contains(number uint32) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	r.rotate()
+
+	return r.find(number) != nil
+}
+
+// Expire forgets the numbers not seen within ttl, returning the amount of numbers expired since last call
+func (r *TTLRepository) Expire() (expired int) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.rotate()
+
+	expired = r.expired
+	r.expired = 0
+
+	return
+}
+
+// ExtractTransaction returns unique numbers list delaying data removal to commit
+func (r *TTLRepository) ExtractTransaction() (uniques []uint32) {
+	r.Lock()
+	for n := range r.nonExtracted {
+		uniques = append(uniques, n)
+	}
+
+	return
+}
+
+// Commit unlocks emptying the numbers to extract
+func (r *TTLRepository) Commit() {
+	r.nonExtracted = make(map[uint32]struct{})
+	r.Unlock()
+}
+
+// Rollback unlocks without data removal
+func (r *TTLRepository) Rollback() {
+	r.Unlock()
+}
+
+// Snapshot writes committed numbers seen within ttl
+func (r *TTLRepository) Snapshot(w io.Writer) error {
+	r.Lock()
+	r.rotate()
+
+	var numbers []uint32
+	for _, bucket := range r.buckets {
+		for n := range bucket {
+			_, pending := r.nonExtracted[n]
+			if !pending {
+				numbers = append(numbers, n)
+			}
+		}
+	}
+	r.Unlock()
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	return writeSnapshot(w, func(add func(uint32) error) error {
+		for _, n := range numbers {
+			err := add(n)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Restore adds snapshot numbers as committed ones just seen
+func (r *TTLRepository) Restore(input io.Reader) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.rotate()
+
+	return readSnapshot(input, func(number uint32) error {
+		delete(r.nonExtracted, number)
+
+		seen := r.find(number)
+		if seen != nil {
+			delete(seen, number)
+		}
+		r.bucket(r.epoch)[number] = struct{}{}
+
+		return nil
+	})
+}
+
+// find returns the bucket holding the number, nil if not seen within ttl
+func (r *TTLRepository) find(number uint32) map[uint32]struct{} {
+	for e := r.epoch; e > r.epoch-int64(len(r.buckets)); e-- {
+		bucket := r.bucket(e)
+
+		_, exists := bucket[number]
+		if exists {
+			return bucket
+		}
+	}
+
+	return nil
+}
+
+// rotate moves to the current bucket, expiring the buckets out of the window
+func (r *TTLRepository) rotate() {
+	current := r.currentEpoch()
+
+	// no need to rotate more than the whole ring
+	if current-r.epoch > int64(len(r.buckets)) {
+		r.epoch = current - int64(len(r.buckets))
+	}
+
+	for r.epoch < current {
+		r.epoch++
+
+		// reuse the oldest bucket as the new current one
+		expiring := r.bucket(r.epoch)
+		r.expired += len(expiring)
+		if len(expiring) > 0 {
+			r.buckets[r.epoch%int64(len(r.buckets))] = make(map[uint32]struct{})
+		}
+	}
+}
+
+func (r *TTLRepository) bucket(epoch int64) map[uint32]struct{} {
+	return r.buckets[epoch%int64(len(r.buckets))]
+}
+
+func (r *TTLRepository) currentEpoch() int64 {
+	return r.now().UnixNano() / int64(r.bucketDuration)
+}
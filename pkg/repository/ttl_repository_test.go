@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock time moved forward by tests
+type fakeClock struct {
+	now time.Time
+	sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.Lock()
+	c.now = c.now.Add(d)
+	c.Unlock()
+}
+
+func newTestTTLRepository(t *testing.T) (*TTLRepository, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	r, err := newTTLRepository(24*time.Hour, 24, clock.Now)
+	if err != nil {
+		t.Fatalf("cannot create ttl repository: %s", err.Error())
+	}
+
+	return r, clock
+}
+
+func TestTTLRepository_AddNumber(t *testing.T) {
+	r, _ := newTestTTLRepository(t)
+
+	for _, n := range []uint32{11, 22, 33} {
+		assert.True(t, r.AddNumber(n))
+	}
+
+	assert.False(t, r.AddNumber(11), "repeated number should not return unique")
+	assert.True(t, r.Contains(22))
+}
+
+func TestTTLRepository_NumberIsUniqueAgainAfterTTL(t *testing.T) {
+	r, clock := newTestTTLRepository(t)
+
+	assert.True(t, r.AddNumber(11))
+	_ = r.ExtractTransaction()
+	r.Commit()
+
+	clock.advance(23 * time.Hour)
+	assert.True(t, r.Contains(11), "number should be kept within ttl")
+
+	clock.advance(2*time.Hour + time.Minute)
+	assert.False(t, r.Contains(11), "number should expire after ttl plus a bucket")
+	assert.Equal(t, 1, r.Expire())
+	assert.Equal(t, 0, r.Expire(), "expired numbers should be returned once")
+
+	assert.True(t, r.AddNumber(11))
+	assert.Equal(t, []uint32{11}, r.ExtractTransaction())
+	r.Commit()
+}
+
+func TestTTLRepository_DuplicateRefreshesLastSeen(t *testing.T) {
+	r, clock := newTestTTLRepository(t)
+
+	assert.True(t, r.AddNumber(11))
+
+	clock.advance(20 * time.Hour)
+	assert.False(t, r.AddNumber(11))
+
+	clock.advance(20 * time.Hour)
+	assert.False(t, r.AddNumber(11), "number seen 20h ago should still be duplicate")
+}
+
+func TestTTLRepository_ExpiresAllAfterLongIdle(t *testing.T) {
+	r, clock := newTestTTLRepository(t)
+
+	for _, n := range []uint32{11, 22, 33} {
+		_ = r.AddNumber(n)
+	}
+
+	clock.advance(30 * 24 * time.Hour)
+
+	assert.Equal(t, 3, r.Expire())
+}
+
+func TestTTLRepository_SnapshotRestoresNumbersAsJustSeen(t *testing.T) {
+	r, _ := newTestTTLRepository(t)
+
+	_ = r.AddNumber(11)
+	_ = r.ExtractTransaction()
+	r.Commit()
+	_ = r.AddNumber(22)
+
+	var snapshot bytes.Buffer
+	assert.NoError(t, r.Snapshot(&snapshot))
+
+	restored, restoredClock := newTestTTLRepository(t)
+	restoredClock.advance(10 * time.Hour)
+	assert.NoError(t, restored.Restore(&snapshot))
+
+	assert.True(t, restored.Contains(11))
+	assert.False(t, restored.Contains(22), "not committed numbers should not be on snapshot")
+
+	restoredClock.advance(24 * time.Hour)
+	assert.True(t, restored.Contains(11), "restored number should be handled as just seen")
+}
+
+func TestNewTTLRepository_FailsOnInvalidSettings(t *testing.T) {
+	_, err := NewTTLRepository(time.Hour, 0)
+	assert.Error(t, err)
+
+	_, err = NewTTLRepository(time.Nanosecond, 24)
+	assert.Error(t, err)
+}
@@ -0,0 +1,51 @@
+package result
+
+import "sync"
+
+// Commits tracks runner flushes, so clients can wait until the numbers they added are durable
+// flushes are numbered in sequence, a flush includes every number added before it started
+type Commits struct {
+	sync.Mutex
+	started   uint64
+	committed uint64
+	changed   chan struct{}
+}
+
+func newCommits() *Commits {
+	return &Commits{
+		changed: make(chan struct{}),
+	}
+}
+
+// Mark returns the flush that will include all numbers added so far, durable once committed
+func (c *Commits) Mark() uint64 {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.started + 1
+}
+
+// Committed returns the last committed flush and a channel closed on next commit
+func (c *Commits) Committed() (flush uint64, changed <-chan struct{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.committed, c.changed
+}
+
+func (c *Commits) start() (flush uint64) {
+	c.Lock()
+	c.started++
+	flush = c.started
+	c.Unlock()
+
+	return
+}
+
+func (c *Commits) commit(flush uint64) {
+	c.Lock()
+	c.committed = flush
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.Unlock()
+}
@@ -0,0 +1,43 @@
+package result
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommits_MarkWaitsForNextFlush(t *testing.T) {
+	c := newCommits()
+
+	assert.Equal(t, uint64(1), c.Mark())
+
+	flush := c.start()
+	assert.Equal(t, uint64(1), flush)
+	assert.Equal(t, uint64(2), c.Mark(), "numbers added after a flush started may not be included on it")
+
+	committed, _ := c.Committed()
+	assert.Equal(t, uint64(0), committed)
+}
+
+func TestCommits_CommitNotifiesChange(t *testing.T) {
+	c := newCommits()
+
+	_, changed := c.Committed()
+
+	c.commit(c.start())
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("commit should close changed channel")
+	}
+
+	committed, changed := c.Committed()
+	assert.Equal(t, uint64(1), committed)
+
+	select {
+	case <-changed:
+		t.Fatal("changed channel should be renewed after commit")
+	default:
+	}
+}
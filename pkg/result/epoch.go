@@ -0,0 +1,65 @@
+package result
+
+import (
+	"sync"
+	"time"
+)
+
+// epochIDFormat epoch start time in UTC, sorting as epochs do
+const epochIDFormat = "20060102T150405Z"
+
+// EpochSchedule splits time on epochs of the given interval, aligned so 24h epochs start at midnight UTC
+// and hourly ones at o'clock
+type EpochSchedule struct {
+	Interval time.Duration
+}
+
+// Start returns when the epoch including given time started
+func (s EpochSchedule) Start(t time.Time) time.Time {
+	return t.UTC().Truncate(s.Interval)
+}
+
+// ID returns the id of the epoch including given time, its UTC start time e.g: 20200101T000000Z
+func (s EpochSchedule) ID(t time.Time) string {
+	return s.Start(t).Format(epochIDFormat)
+}
+
+// epochs runner current epoch, rotated on each schedule boundary
+type epochs struct {
+	schedule EpochSchedule
+	// writes the numbers of the ending epoch, finalizing its log with given epoch id and forgetting its numbers
+	rotateTransaction func(epochID string) error
+	current           time.Time
+	sync.Mutex
+}
+
+func newEpochs(schedule EpochSchedule, rotateTransaction func(string) error) *epochs {
+	return &epochs{
+		schedule:          schedule,
+		rotateTransaction: rotateTransaction,
+		current:           schedule.Start(time.Now()),
+	}
+}
+
+// untilNext returns the time left until the current epoch ends
+func (e *epochs) untilNext() time.Duration {
+	e.Lock()
+	defer e.Unlock()
+
+	return time.Until(e.current.Add(e.schedule.Interval))
+}
+
+// id returns the current epoch id
+func (e *epochs) id() string {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.current.Format(epochIDFormat)
+}
+
+// begin moves to the epoch including given time
+func (e *epochs) begin(t time.Time) {
+	e.Lock()
+	e.current = e.schedule.Start(t)
+	e.Unlock()
+}
@@ -0,0 +1,48 @@
+package result
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+func TestEpochSchedule_StartsAtMidnightUTC(t *testing.T) {
+	s := EpochSchedule{Interval: 24 * time.Hour}
+
+	at := time.Date(2020, 1, 1, 15, 4, 5, 0, time.FixedZone("CET", 3600))
+
+	assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), s.Start(at))
+	assert.Equal(t, "20200101T000000Z", s.ID(at))
+}
+
+func TestEpochSchedule_StartsEveryInterval(t *testing.T) {
+	s := EpochSchedule{Interval: 6 * time.Hour}
+
+	assert.Equal(t, "20200101T120000Z", s.ID(time.Date(2020, 1, 1, 17, 59, 59, 0, time.UTC)))
+	assert.Equal(t, "20200101T180000Z", s.ID(time.Date(2020, 1, 1, 18, 0, 0, 0, time.UTC)))
+}
+
+func TestRunner_RotatesLogAndRestartsDedupeOnEpochEnd(t *testing.T) {
+	finalizedPath := testFilePath + "." + EpochSchedule{Interval: time.Hour}.ID(time.Now())
+	defer os.Remove(finalizedPath)
+
+	w, err := NewWriter(testFilePath, 2)
+	assert.NoError(t, err)
+
+	numberRepo := repository.NewInMemoryRepository().(repository.ResettableRepository)
+	runner := NewEpochRunner(time.Hour, EpochSchedule{Interval: time.Hour}, w, numberRepo)
+
+	assert.True(t, numberRepo.AddNumber(11))
+	assert.NoError(t, runner.rotate(time.Now().Add(time.Hour)))
+
+	assert.True(t, numberRepo.AddNumber(11), "number should be unique on the new epoch")
+	assert.NoError(t, runner.flush())
+	assert.NoError(t, w.Close())
+
+	assertFileContains(t, finalizedPath, []uint32{11})
+	assertFileContains(t, testFilePath, []uint32{11})
+	assert.Equal(t, EpochSchedule{Interval: time.Hour}.ID(time.Now().Add(time.Hour)), runner.Epoch())
+}
@@ -2,18 +2,56 @@ package result
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"fmt"
 
+	"github.com/varas/numserver/pkg/errhandler"
 	"github.com/varas/numserver/pkg/repository"
 )
 
 // Runner prints a flush to standard output every 10 seconds
 type Runner struct {
-	interval   time.Duration
-	writer     *Writer
-	numberRepo repository.NumberRepository
+	interval time.Duration
+	sink     Sink
+	// writes repository unique numbers on a transaction, committed only if written
+	writeTransaction func(Sink) error
+	commits          *Commits
+	// nil if epochs are disabled
+	epochs *epochs
+	// settings changed at runtime, applied by Run
+	settings        runnerSettings
+	settingsChanged chan struct{}
+	// handles errors not stopping the runner
+	errHandle errhandler.ErrHandler
+}
+
+// notPersistedError numbers written and committed that the repository failed to persist, retried on next commit
+type notPersistedError struct {
+	error
+}
+
+// persistErr returns the error persisting the last commit of repositories persisting them, nil otherwise
+func persistErr(numberRepo interface{}) error {
+	persistent, ok := numberRepo.(repository.PersistentRepository)
+	if !ok {
+		return nil
+	}
+
+	err := persistent.PersistErr()
+	if err != nil {
+		return notPersistedError{err}
+	}
+
+	return nil
+}
+
+// runnerSettings settings changeable while running
+type runnerSettings struct {
+	interval       time.Duration
+	flushBatchSize int
+	sync.Mutex
 }
 
 // NewRunner creates a new daemon to write results on each interval
@@ -23,42 +61,244 @@ func NewRunner(interval time.Duration, logPath string, logFlushBatchSize int, nu
 		return nil, fmt.Errorf("cannot create result writer: %s", err.Error())
 	}
 
+	return NewRunnerWithWriter(interval, writer, numberRepo), nil
+}
+
+// NewRunnerWithWriter creates a new daemon to write results with given writer on each interval
+func NewRunnerWithWriter(interval time.Duration, writer *Writer, numberRepo repository.NumberRepository) *Runner {
+	return NewRunnerWithSink(interval, writer, numberRepo)
+}
+
+// NewRunnerWithSink creates a new daemon to write results to given sink on each interval, closing it once done
+func NewRunnerWithSink(interval time.Duration, sink Sink, numberRepo repository.NumberRepository) *Runner {
+	return newRunner(interval, sink, func(s Sink) error {
+		err := s.Write(numberRepo.ExtractTransaction())
+		if err != nil {
+			numberRepo.Rollback()
+			return err
+		}
+
+		numberRepo.Commit()
+
+		return persistErr(numberRepo)
+	})
+}
+
+// NewRunner64 creates a new daemon to write results of a 64-bit repository on each interval
+func NewRunner64(interval time.Duration, logPath string, logFlushBatchSize int, numberRepo repository.NumberRepository64) (*Runner, error) {
+	writer, err := NewWriter(logPath, logFlushBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create result writer: %s", err.Error())
+	}
+
+	return NewRunner64WithWriter(interval, writer, numberRepo), nil
+}
+
+// NewRunner64WithWriter creates a new daemon to write results of a 64-bit repository with given writer on each interval
+func NewRunner64WithWriter(interval time.Duration, writer *Writer, numberRepo repository.NumberRepository64) *Runner {
+	return NewRunner64WithSink(interval, writer, numberRepo)
+}
+
+// NewRunner64WithSink creates a new daemon to write results of a 64-bit repository to given sink on each interval,
+// closing it once done
+func NewRunner64WithSink(interval time.Duration, sink Sink, numberRepo repository.NumberRepository64) *Runner {
+	return newRunner(interval, sink, func(s Sink) error {
+		err := s.Write64(numberRepo.ExtractTransaction())
+		if err != nil {
+			numberRepo.Rollback()
+			return err
+		}
+
+		numberRepo.Commit()
+
+		return persistErr(numberRepo)
+	})
+}
+
+// NewEpochRunner creates a new daemon to write results on each interval, restarting dedupe scope on each epoch:
+// at each boundary the log is finalized appending the epoch id to its path, a new one is started
+// and the repository numbers are forgotten
+func NewEpochRunner(interval time.Duration, schedule EpochSchedule, writer *Writer, numberRepo repository.ResettableRepository) *Runner {
+	runner := NewRunnerWithWriter(interval, writer, numberRepo)
+
+	runner.epochs = newEpochs(schedule, func(epochID string) error {
+		err := writer.Write(numberRepo.ExtractTransaction())
+		if err != nil {
+			numberRepo.Rollback()
+			return err
+		}
+
+		err = writer.Rotate(writer.path + "." + epochID)
+		if err != nil {
+			// written on the current log, so the epoch goes on
+			numberRepo.Commit()
+			return err
+		}
+
+		numberRepo.CommitAndReset()
+
+		return nil
+	})
+
+	return runner
+}
+
+func newRunner(interval time.Duration, sink Sink, writeTransaction func(Sink) error) *Runner {
+	var flushBatchSize int
+	if writer, ok := sink.(*Writer); ok {
+		flushBatchSize = writer.flushBatchSize
+	}
+
 	return &Runner{
-		interval:   interval,
-		writer:     writer,
-		numberRepo: numberRepo,
-	}, nil
+		interval:         interval,
+		sink:             sink,
+		writeTransaction: writeTransaction,
+		commits:          newCommits(),
+		settings: runnerSettings{
+			interval:       interval,
+			flushBatchSize: flushBatchSize,
+		},
+		settingsChanged: make(chan struct{}, 1),
+		errHandle:       errhandler.Noop,
+	}
+}
+
+// SetErrHandler handles errors not stopping the runner, like repository persistence ones, to be called before Run
+func (r *Runner) SetErrHandler(errHandle errhandler.ErrHandler) {
+	r.errHandle = errHandle
+}
+
+// SetInterval changes the write interval, applied from the next write on
+func (r *Runner) SetInterval(interval time.Duration) {
+	r.settings.Lock()
+	r.settings.interval = interval
+	r.settings.Unlock()
+
+	r.notifySettingsChanged()
+}
+
+// SetFlushBatchSize changes the amount of numbers written to file at once, applied from the next write on,
+// only used by Writer sinks
+func (r *Runner) SetFlushBatchSize(flushBatchSize int) {
+	r.settings.Lock()
+	r.settings.flushBatchSize = flushBatchSize
+	r.settings.Unlock()
+
+	r.notifySettingsChanged()
+}
+
+func (r *Runner) notifySettingsChanged() {
+	select {
+	case r.settingsChanged <- struct{}{}:
+	default:
+		// already pending
+	}
+}
+
+// applySettings applies settings changed at runtime, returning the ticker for the current interval
+func (r *Runner) applySettings(ticker *time.Ticker) *time.Ticker {
+	r.settings.Lock()
+	defer r.settings.Unlock()
+
+	// writer is only used from Run
+	if writer, ok := r.sink.(*Writer); ok {
+		writer.flushBatchSize = r.settings.flushBatchSize
+	}
+
+	if r.settings.interval == r.interval {
+		return ticker
+	}
+
+	r.interval = r.settings.interval
+	ticker.Stop()
+
+	return time.NewTicker(r.interval)
+}
+
+// Commits returns the flushes tracker, to wait for numbers being durable
+func (r *Runner) Commits() *Commits {
+	return r.commits
+}
+
+// Epoch returns the current epoch id, empty if epochs are disabled
+func (r *Runner) Epoch() string {
+	if r.epochs == nil {
+		return ""
+	}
+
+	return r.epochs.id()
 }
 
 // Run runs writing results on each interval
 func (r *Runner) Run(ctx context.Context) (err error) {
 	ticker := time.NewTicker(r.interval)
+	defer func() { ticker.Stop() }()
+
+	// never fires if epochs are disabled
+	var epochEnd <-chan time.Time
+	if r.epochs != nil {
+		epochTimer := time.NewTimer(r.epochs.untilNext())
+		defer epochTimer.Stop()
+		epochEnd = epochTimer.C
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			err = r.flush()
-			r.writer.Close()
+			r.sink.Close()
 			return
 
 		case <-ticker.C:
 			err = r.flush()
 			if err != nil {
-				r.writer.Close()
+				r.sink.Close()
+				return
+			}
+
+		case <-r.settingsChanged:
+			ticker = r.applySettings(ticker)
+
+		case now := <-epochEnd:
+			err = r.rotate(now)
+			if err != nil {
+				r.sink.Close()
 				return
 			}
+			epochEnd = time.After(r.epochs.untilNext())
 		}
 	}
 }
 
 func (r *Runner) flush() error {
-	err := r.writer.Write(r.numberRepo.ExtractTransaction())
+	flush := r.commits.start()
+
+	err := r.writeTransaction(r.sink)
+	if notPersisted, ok := err.(notPersistedError); ok {
+		// numbers are logged, so the flush is done
+		r.errHandle(notPersisted.error)
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.commits.commit(flush)
+
+	return nil
+}
+
+// rotate ends the current epoch, flushing its numbers to its finalized log
+func (r *Runner) rotate(now time.Time) error {
+	flush := r.commits.start()
+
+	err := r.epochs.rotateTransaction(r.epochs.id())
 	if err != nil {
-		r.numberRepo.Rollback()
 		return err
 	}
 
-	r.numberRepo.Commit()
+	r.commits.commit(flush)
+	r.epochs.begin(now)
 
 	return nil
 }
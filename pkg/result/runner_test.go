@@ -0,0 +1,79 @@
+package result
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+func TestRunner_ReportsPersistErrorsWithoutStopping(t *testing.T) {
+	numberRepo := &unsyncedRepository{NumberRepository: repository.NewInMemoryRepository()}
+	numberRepo.AddNumber(1)
+
+	sink := &memorySink{}
+	runner := NewRunnerWithSink(10*time.Millisecond, sink, numberRepo)
+
+	handled := int32(0)
+	runner.SetErrHandler(func(err error) {
+		assert.EqualError(t, err, "cannot sync")
+		atomic.AddInt32(&handled, 1)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runner.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&handled) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	numberRepo.AddNumber(2)
+	for len(sink.written()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	assert.NoError(t, <-done, "runner should keep running")
+	assert.Equal(t, []uint32{1, 2}, sink.written())
+	assert.True(t, atomic.LoadInt32(&handled) > 1)
+}
+
+// unsyncedRepository repository failing to persist every commit
+type unsyncedRepository struct {
+	repository.NumberRepository
+}
+
+func (r *unsyncedRepository) PersistErr() error {
+	return errors.New("cannot sync")
+}
+
+type memorySink struct {
+	numbers []uint32
+	sync.Mutex
+}
+
+func (s *memorySink) Write(numbers []uint32) error {
+	s.Lock()
+	defer s.Unlock()
+	s.numbers = append(s.numbers, numbers...)
+	return nil
+}
+
+func (s *memorySink) Write64([]uint64) error { return nil }
+
+func (s *memorySink) Close() error { return nil }
+
+func (s *memorySink) written() []uint32 {
+	s.Lock()
+	defer s.Unlock()
+	return append([]uint32{}, s.numbers...)
+}
@@ -0,0 +1,10 @@
+package result
+
+// Sink receives the unique numbers of each flush, they are committed only if written without error,
+// so acked clients rely on them being durable once written
+// Writer is the sink writing them to a log file
+type Sink interface {
+	Write(numbers []uint32) error
+	Write64(numbers []uint64) error
+	Close() error
+}
@@ -3,10 +3,12 @@ package result
 import (
 	"fmt"
 	"os"
+	"strconv"
 )
 
 // Writer writes results to file
 type Writer struct {
+	path           string
 	fd             *os.File
 	flushBatchSize int
 }
@@ -18,32 +20,109 @@ func NewWriter(filePath string, flushBatchSize int) (*Writer, error) {
 		return nil, fmt.Errorf("cannot create log file: %s", err.Error())
 	}
 
+	return newWriter(filePath, output, flushBatchSize), nil
+}
+
+// NewAppendWriter creates a new writer appending to the given file, to be used when numbers logged are kept on restart
+func NewAppendWriter(filePath string, flushBatchSize int) (*Writer, error) {
+	output, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open log file: %s", err.Error())
+	}
+
+	return newWriter(filePath, output, flushBatchSize), nil
+}
+
+func newWriter(filePath string, output *os.File, flushBatchSize int) *Writer {
 	return &Writer{
+		path:           filePath,
 		fd:             output,
 		flushBatchSize: flushBatchSize,
-	}, nil
+	}
 }
 
 // Write writes numbers flushing to file on batches
-func (r *Writer) Write(numbers []uint32) (err error) {
-	lines := ""
+func (r *Writer) Write(numbers []uint32) error {
+	return r.writeBatches(len(numbers), func(lines []byte, i int) []byte {
+		return strconv.AppendUint(lines, uint64(numbers[i]), 10)
+	})
+}
 
-	i := 0
-	amount := len(numbers)
-	for _, n := range numbers {
-		lines = fmt.Sprintf("%d%s%s", n, "\n", lines)
+// Write64 writes 64-bit numbers flushing to file on batches
+func (r *Writer) Write64(numbers []uint64) error {
+	return r.writeBatches(len(numbers), func(lines []byte, i int) []byte {
+		return strconv.AppendUint(lines, numbers[i], 10)
+	})
+}
+
+// writeBatches writes amount lines, appending each number to the current batch
+func (r *Writer) writeBatches(amount int, appendNumber func(lines []byte, i int) []byte) (err error) {
+	var lines []byte
 
-		i++
-		if i%r.flushBatchSize == 0 || i == amount {
-			_, err = r.fd.WriteString(lines)
+	for i := 0; i < amount; i++ {
+		lines = append(appendNumber(lines, i), '\n')
+
+		if (i+1)%r.flushBatchSize == 0 || i+1 == amount {
+			_, err = r.fd.Write(lines)
 			if err != nil {
 				return
 			}
-			lines = ""
+			lines = lines[:0]
+		}
+	}
+
+	// durable once written, as acked clients rely on it
+	return r.fd.Sync()
+}
+
+// Rotate finalizes the file moving it to given path, going on writing on a new empty file
+// an existing file on that path is kept, e.g. a repeated epoch after a clock step back, suffixing a number instead
+func (r *Writer) Rotate(finalizedPath string) error {
+	finalizedPath, err := freePath(finalizedPath)
+	if err != nil {
+		return fmt.Errorf("cannot finalize log file: %s", err.Error())
+	}
+
+	err = r.fd.Close()
+	if err != nil {
+		return fmt.Errorf("cannot close log file: %s", err.Error())
+	}
+
+	err = os.Rename(r.path, finalizedPath)
+	if err != nil {
+		// go on with the current file
+		output, openErr := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND, 0666)
+		if openErr != nil {
+			return fmt.Errorf("cannot reopen log file: %s", openErr.Error())
 		}
+		r.fd = output
+
+		return fmt.Errorf("cannot finalize log file: %s", err.Error())
 	}
 
-	return
+	output, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("cannot create log file: %s", err.Error())
+	}
+	r.fd = output
+
+	return nil
+}
+
+// freePath returns path if no file exists on it, otherwise the first one free suffixing a number, e.g. path.1
+func freePath(path string) (string, error) {
+	candidate := path
+	for i := 1; ; i++ {
+		_, err := os.Lstat(candidate)
+		if os.IsNotExist(err) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		candidate = fmt.Sprintf("%s.%d", path, i)
+	}
 }
 
 // Close closes result file
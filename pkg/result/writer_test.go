@@ -27,6 +27,37 @@ func TestWriter_Write(t *testing.T) {
 	assertFileContains(t, testFilePath, numbers)
 }
 
+func TestWriter_Write64(t *testing.T) {
+	numbers := []uint64{9999999999999999999, 1 << 40, 001}
+
+	w, err := NewWriter(testFilePath, 2)
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.Write64(numbers))
+
+	content, err := ioutil.ReadFile(testFilePath)
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(numbers), strings.Count(string(content), "\n"))
+	for _, n := range numbers {
+		assert.True(t, strings.Contains(string(content), fmt.Sprintf("%d\n", n)))
+	}
+}
+
+func TestAppendWriter_KeepsExistingLines(t *testing.T) {
+	w, err := NewWriter(testFilePath, 2)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write([]uint32{11}))
+	assert.NoError(t, w.Close())
+
+	w, err = NewAppendWriter(testFilePath, 2)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write([]uint32{22, 33}))
+	assert.NoError(t, w.Close())
+
+	assertFileContains(t, testFilePath, []uint32{11, 22, 33})
+}
+
 func assertFileContains(t *testing.T, filePath string, expectedNumbers []uint32) {
 	content, err := ioutil.ReadFile(filePath)
 	assert.NoError(t, err)
@@ -38,3 +69,37 @@ func assertFileContains(t *testing.T, filePath string, expectedNumbers []uint32)
 		assert.True(t, strings.Contains(string(content), fmt.Sprintf("%d\n", n)))
 	}
 }
+
+func TestWriter_Rotate(t *testing.T) {
+	finalizedPath := testFilePath + ".finalized"
+	defer os.Remove(finalizedPath)
+
+	w, err := NewWriter(testFilePath, 2)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write([]uint32{11}))
+
+	assert.NoError(t, w.Rotate(finalizedPath))
+	assert.NoError(t, w.Write([]uint32{22}))
+	assert.NoError(t, w.Close())
+
+	assertFileContains(t, finalizedPath, []uint32{11})
+	assertFileContains(t, testFilePath, []uint32{22})
+}
+
+func TestWriter_RotateKeepsExistingFinalizedFile(t *testing.T) {
+	finalizedPath := testFilePath + ".finalized"
+	defer os.Remove(finalizedPath)
+	defer os.Remove(finalizedPath + ".1")
+
+	assert.NoError(t, ioutil.WriteFile(finalizedPath, []byte("11\n"), 0644))
+
+	w, err := NewWriter(testFilePath, 2)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write([]uint32{22}))
+
+	assert.NoError(t, w.Rotate(finalizedPath))
+	assert.NoError(t, w.Close())
+
+	assertFileContains(t, finalizedPath, []uint32{11})
+	assertFileContains(t, finalizedPath+".1", []uint32{22})
+}
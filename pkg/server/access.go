@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net"
+	"sync"
+)
+
+// AccessPolicy filters connections by source address at accept time, its zero value accepts everything
+// * Allow: only sources within these networks are accepted, any source if empty
+// * Deny: sources within these networks are rejected, takes precedence over Allow
+// * MaxConnsPerIP: max concurrent connections per source ip, unlimited if 0
+type AccessPolicy struct {
+	Allow         []*net.IPNet
+	Deny          []*net.IPNet
+	MaxConnsPerIP int
+}
+
+// accessControl applies an access policy keeping track of open connections per source ip
+type accessControl struct {
+	policy    AccessPolicy
+	connsByIP map[string]int
+	sync.Mutex
+}
+
+func newAccessControl(policy AccessPolicy) *accessControl {
+	return &accessControl{
+		policy:    policy,
+		connsByIP: make(map[string]int),
+	}
+}
+
+// admit returns the connection to be handled, that releases its slot on close, or false if rejected
+func (a *accessControl) admit(conn net.Conn) (net.Conn, bool) {
+	ip := remoteIP(conn)
+	// non ip connections (e.g. unix sockets) are local, so not filtered
+	if ip == nil {
+		return conn, true
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if !a.allows(ip) {
+		return nil, false
+	}
+
+	if a.policy.MaxConnsPerIP <= 0 {
+		return conn, true
+	}
+
+	key := ip.String()
+
+	if a.connsByIP[key] >= a.policy.MaxConnsPerIP {
+		return nil, false
+	}
+	a.connsByIP[key]++
+
+	return &releasingConn{Conn: conn, release: func() { a.release(key) }}, true
+}
+
+func (a *accessControl) isAllowed(ip net.IP) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.allows(ip)
+}
+
+// allows returns whether the policy accepts given ip, must be called locked
+func (a *accessControl) allows(ip net.IP) bool {
+	if containsIP(a.policy.Deny, ip) {
+		return false
+	}
+
+	return len(a.policy.Allow) == 0 || containsIP(a.policy.Allow, ip)
+}
+
+// setPolicy changes the policy applied to new connections, already admitted ones are kept
+func (a *accessControl) setPolicy(policy AccessPolicy) {
+	a.Lock()
+	a.policy = policy
+	a.Unlock()
+}
+
+func (a *accessControl) release(key string) {
+	a.Lock()
+	a.connsByIP[key]--
+	if a.connsByIP[key] <= 0 {
+		delete(a.connsByIP, key)
+	}
+	a.Unlock()
+}
+
+// releasingConn releases its access slot once closed
+type releasingConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+// Close closes the connection releasing its access slot
+func (c *releasingConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func remoteIP(conn net.Conn) net.IP {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+
+	return addr.IP
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/line"
+)
+
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.remote }
+func (c *addrConn) Close() error         { return nil }
+
+func connFrom(ip string) net.Conn {
+	return &addrConn{remote: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}}
+}
+
+func TestAccessControl_ZeroPolicyAdmitsAll(t *testing.T) {
+	a := newAccessControl(AccessPolicy{})
+
+	_, ok := a.admit(connFrom("192.168.1.10"))
+
+	assert.True(t, ok)
+}
+
+func TestAccessControl_AllowAndDenyLists(t *testing.T) {
+	allow, err := line.ParseNetworks([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	deny, err := line.ParseNetworks([]string{"10.0.1.0/24"})
+	assert.NoError(t, err)
+
+	a := newAccessControl(AccessPolicy{Allow: allow, Deny: deny})
+
+	_, ok := a.admit(connFrom("10.0.0.1"))
+	assert.True(t, ok)
+
+	_, ok = a.admit(connFrom("10.0.1.1"))
+	assert.False(t, ok, "deny should take precedence over allow")
+
+	_, ok = a.admit(connFrom("192.168.1.10"))
+	assert.False(t, ok, "sources out of allow list should be rejected")
+}
+
+func TestAccessControl_MaxConnsPerIP(t *testing.T) {
+	a := newAccessControl(AccessPolicy{MaxConnsPerIP: 1})
+
+	first, ok := a.admit(connFrom("10.0.0.1"))
+	assert.True(t, ok)
+
+	_, ok = a.admit(connFrom("10.0.0.1"))
+	assert.False(t, ok, "exceeding connections per ip should be rejected")
+
+	_, ok = a.admit(connFrom("10.0.0.2"))
+	assert.True(t, ok, "other ips should not be limited")
+
+	assert.NoError(t, first.Close())
+	assert.NoError(t, first.Close(), "closing twice should release once")
+
+	_, ok = a.admit(connFrom("10.0.0.1"))
+	assert.True(t, ok, "closed connections should release their slot")
+}
+
+func TestAccessControl_SetPolicyAppliesToNewConnections(t *testing.T) {
+	deny, err := line.ParseNetworks([]string{"10.0.1.0/24"})
+	assert.NoError(t, err)
+
+	a := newAccessControl(AccessPolicy{})
+
+	_, ok := a.admit(connFrom("10.0.1.1"))
+	assert.True(t, ok)
+
+	a.setPolicy(AccessPolicy{Deny: deny})
+
+	_, ok = a.admit(connFrom("10.0.1.1"))
+	assert.False(t, ok)
+	assert.False(t, a.isAllowed(net.ParseIP("10.0.1.1")))
+}
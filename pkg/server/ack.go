@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/varas/numserver/pkg/result"
+)
+
+// protocolAck acknowledged wire protocol, negotiated by "hello ack" first line
+// server replies "hello ack" and then "ack <count>" each time the first count lines sent are durable,
+// so clients can safely resend the lines after count on reconnect
+const protocolAck = "ack"
+
+// pending lines waiting for a flush to be committed
+type ackMark struct {
+	flush uint64
+	count uint64
+}
+
+// ackWriter writes acks for the lines processed on a connection as their flushes are committed
+type ackWriter struct {
+	commits *result.Commits
+	output  io.Writer
+	count   uint64
+	pending []ackMark
+	closed  chan struct{}
+	sync.Mutex
+}
+
+func newAckWriter(commits *result.Commits, output io.Writer) *ackWriter {
+	return &ackWriter{
+		commits: commits,
+		output:  output,
+		closed:  make(chan struct{}),
+	}
+}
+
+// processed marks a line as processed, so acked once its flush is committed
+func (a *ackWriter) processed(lineResult) {
+	flush := a.commits.Mark()
+
+	a.Lock()
+	a.count++
+	last := len(a.pending) - 1
+	if last >= 0 && a.pending[last].flush == flush {
+		a.pending[last].count = a.count
+	} else {
+		a.pending = append(a.pending, ackMark{flush: flush, count: a.count})
+	}
+	a.Unlock()
+}
+
+// close stops waiting for more lines, run returns once the processed ones are acked
+func (a *ackWriter) close() {
+	close(a.closed)
+}
+
+// run writes acks until closed and all lines acked, or context done
+func (a *ackWriter) run(ctx context.Context) error {
+	closed := a.closed
+
+	for {
+		committed, changed := a.commits.Committed()
+
+		count, done := a.acked(committed, closed == nil)
+		if count > 0 {
+			_, err := fmt.Fprintf(a.output, "ack %d\n", count)
+			if err != nil {
+				return err
+			}
+		}
+
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-closed:
+			closed = nil
+		case <-changed:
+		}
+	}
+}
+
+// acked pops pending lines of committed flushes returning its count, 0 if none
+// done if all lines have been acked and no more are expected
+func (a *ackWriter) acked(committed uint64, closed bool) (count uint64, done bool) {
+	a.Lock()
+	defer a.Unlock()
+
+	for len(a.pending) > 0 && a.pending[0].flush <= committed {
+		count = a.pending[0].count
+		a.pending = a.pending[1:]
+	}
+
+	return count, closed && len(a.pending) == 0
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/errhandler"
+)
+
+func TestNumServer_AcksLinesOnceDurable(t *testing.T) {
+	dir := t.TempDir()
+
+	port := randPort()
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	runServerWithConfig(errhandler.Noop, func(c *Config) {
+		c.Port = port
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+	})
+
+	client, err := net.Dial("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	defer client.Close()
+
+	_, err = client.Write([]byte("hello ack\n" + validMultiLineInput + "short\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.(*net.TCPConn).CloseWrite())
+
+	replies, err := ioutil.ReadAll(bufio.NewReader(client))
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(replies), "\n"), "\n")
+	assert.Equal(t, "hello ack", lines[0])
+	assert.Equal(t, "ack 3", lines[len(lines)-1], "all lines, including invalid ones, should be acked")
+
+	// acked lines should be already on log
+	content, err := ioutil.ReadFile(logPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "7007009\n")
+	assert.Contains(t, string(content), "314159265\n")
+}
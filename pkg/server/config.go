@@ -1,6 +1,17 @@
 package server
 
-import "time"
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/line"
+	"github.com/varas/numserver/pkg/result"
+)
 
 // Default config values
 const (
@@ -10,28 +21,262 @@ const (
 	DefaultLogFlushInterval    = 1 * time.Second
 	DefaultReportFlushInterval = 1 * time.Second
 	DefaultConcurrentClients   = 5
+	// server logs
+	DefaultLoggingLevel  = "info"
+	DefaultLoggingFormat = errhandler.FormatText
+	// report file
+	DefaultReportFileMaxSize = 10 << 20
+	DefaultReportFileBackups = 3
+	// bloom repository
+	DefaultBloomCapacity          = 100000000
+	DefaultBloomFalsePositiveRate = 0.01
+	DefaultSnapshotInterval       = 10 * time.Minute
+	// ttl repository
+	DefaultTTL        = 24 * time.Hour
+	DefaultTTLBuckets = 24
 )
 
-type config struct {
-	port    int
-	logPath string
+// Repository types
+const (
+	RepositoryMemory = "memory"
+	RepositoryBloom  = "bloom"
+	RepositoryDisk   = "disk"
+	RepositoryMmap   = "mmap"
+	RepositoryTTL    = "ttl"
+)
+
+// max digits of numbers fitting on 32 bits
+const maxDigits32 = 9
+
+// Config numserver configuration, use NewConfig to get one filled with defaults
+type Config struct {
+	Port int
+	// [network:]address list to listen on, e.g: "127.0.0.1:4000", "tcp6:[::1]:4000", "unix:/tmp/numserver.sock"
+	// all interfaces on Port if empty
+	ListenAddresses []string
+	// unix socket settings, its Path is listened besides ListenAddresses
+	UnixSocket UnixSocketConfig
+	// http address to serve POST /numbers on, e.g: ":8080", disabled if empty
+	HTTPAddress string
+	// address to serve read-only membership queries on, e.g: "127.0.0.1:4001", disabled if empty
+	QueryAddress string
+	// udp address to read datagrams from, e.g: ":4000", disabled if empty
+	UDPAddress string
+	LogPath    string
+	// valid number lines
+	LineFormat line.Format
 	// write numbers to file in batches
-	logFlushBatchSize int
+	LogFlushBatchSize int
 	// flush to log interval
-	logFlushInterval time.Duration
+	LogFlushInterval time.Duration
 	// report interval
-	reportFlushInterval time.Duration
-	// allowed concurrent clients
-	concurrentClients int
+	ReportFlushInterval time.Duration
+	// file reports are written to besides standard output, disabled by default
+	ReportFile ReportFileConfig
+	// allowed concurrent clients, shared by tcp and http ingestion
+	ConcurrentClients int
+	// tcp connections waiting for input for this long are closed, disabled if 0
+	IdleTimeout time.Duration
+	// serve over tls when enabled
+	TLS TLSConfig
+	// which sources are accepted and how many connections each
+	Access AccessPolicy
+	// who is allowed to terminate the server, anyone by default
+	Termination line.TerminationPolicy
+	// how unique numbers are stored
+	Repository RepositoryConfig
+	// repository snapshots, disabled by default
+	Snapshot SnapshotConfig
+	// dedupe scope restarts on each epoch finalizing its log, disabled if Interval is 0
+	Epochs result.EpochSchedule
+	// write-ahead log directory for numbers accepted on tcp connections but not logged yet, disabled if empty
+	WALDir string
+	// server logs on standard error
+	Logging LoggingConfig
 }
 
-func newConfig(port int, logPath string) *config {
-	return &config{
-		port:                port,
-		logPath:             logPath,
-		logFlushBatchSize:   DefaultLogFlushBatchSize,
-		logFlushInterval:    DefaultLogFlushInterval,
-		reportFlushInterval: DefaultReportFlushInterval,
-		concurrentClients:   DefaultConcurrentClients,
+// RepositoryConfig unique numbers storage settings
+type RepositoryConfig struct {
+	// RepositoryMemory, RepositoryBloom, RepositoryDisk, RepositoryMmap or RepositoryTTL,
+	// all but memory only available for numbers fitting on 32 bits
+	Type string
+	// file storing numbers of all but memory repository, LogPath with ".bitmap" suffix if empty
+	// disk and mmap ones keep it on restart, the bloom one truncates it
+	Path string
+	// bloom filter expected amount of unique numbers
+	BloomCapacity int
+	// bloom filter false positive rate while under capacity, the ratio of new numbers checked on disk
+	BloomFalsePositiveRate float64
+	// ttl repository numbers are unique again once not seen for TTL
+	TTL time.Duration
+	// ttl repository rolling window buckets, numbers expire a bucket late at most
+	TTLBuckets int
+}
+
+// ReportFileConfig report file settings
+type ReportFileConfig struct {
+	// file appended reports, disabled if empty
+	Path string
+	// bytes before rotating it, never rotated if 0
+	MaxSize int
+	// rotated files kept as Path with .1, .2... suffix, the newest first
+	Backups int
+}
+
+// LoggingConfig server logs settings
+type LoggingConfig struct {
+	// min level logged: "debug", "info", "warn" or "error"
+	Level string
+	// errhandler.FormatText or errhandler.FormatJSON
+	Format errhandler.Format
+}
+
+// newLogger creates the configured structured logger writing to w
+func (c LoggingConfig) newLogger(w io.Writer) (*slog.Logger, error) {
+	return errhandler.NewLogger(w, c.Format, c.Level)
+}
+
+// SnapshotConfig repository snapshots settings, only available for numbers fitting on 32 bits
+type SnapshotConfig struct {
+	// snapshot file restored on start if exists, and saved on each interval and on stop, disabled if empty
+	Path string
+	// 0 saves it only on stop
+	Interval time.Duration
+}
+
+// withReloaded returns the config with the settings applied on reload taken from given one,
+// the rest are only applied on start
+func (c Config) withReloaded(reloaded Config) Config {
+	c.ReportFlushInterval = reloaded.ReportFlushInterval
+	c.LogFlushInterval = reloaded.LogFlushInterval
+	c.LogFlushBatchSize = reloaded.LogFlushBatchSize
+	c.ConcurrentClients = reloaded.ConcurrentClients
+	c.IdleTimeout = reloaded.IdleTimeout
+	c.Access = reloaded.Access
+
+	return c
+}
+
+// Validate returns an error listing every invalid setting
+func (c Config) Validate() error {
+	var invalid []string
+	check := func(valid bool, format string, args ...interface{}) {
+		if !valid {
+			invalid = append(invalid, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Port >= 0 && c.Port <= 65535, "port %d out of range", c.Port)
+	check(c.LogPath != "", "log file is required")
+
+	if err := c.LineFormat.Validate(); err != nil {
+		invalid = append(invalid, err.Error())
+	}
+	fits32 := c.LineFormat.Digits <= maxDigits32
+
+	check(c.ReportFlushInterval > 0, "report interval must be positive")
+	check(c.LogFlushInterval > 0, "log flush interval must be positive")
+	check(c.LogFlushBatchSize > 0, "log flush batch size must be positive")
+	check(c.ConcurrentClients > 0, "concurrent clients must be positive")
+	check(c.IdleTimeout >= 0, "idle timeout cannot be negative")
+	check(c.ReportFile.MaxSize >= 0, "report file max size cannot be negative")
+	check(c.ReportFile.Backups >= 0, "report file backups cannot be negative")
+	check(c.Access.MaxConnsPerIP >= 0, "max connections per ip cannot be negative")
+
+	if _, err := c.Logging.newLogger(io.Discard); err != nil {
+		invalid = append(invalid, err.Error())
 	}
+
+	check(c.TLS.CertFile != "" || c.TLS.KeyFile == "", "tls key requires a tls cert")
+	check(c.TLS.KeyFile != "" || c.TLS.CertFile == "", "tls cert requires a tls key")
+	check(c.TLS.Enabled() || c.TLS.ClientCAFile == "", "tls client ca requires tls cert and key")
+
+	switch c.Repository.Type {
+	case RepositoryMemory:
+	case RepositoryBloom:
+		check(c.Repository.BloomCapacity > 0, "bloom capacity must be positive")
+		check(c.Repository.BloomFalsePositiveRate > 0 && c.Repository.BloomFalsePositiveRate < 1, "bloom false positive rate must be between 0 and 1")
+	case RepositoryTTL:
+		check(c.Repository.TTL > 0, "ttl must be positive")
+		check(c.Repository.TTLBuckets > 0, "ttl buckets must be positive")
+	case RepositoryDisk, RepositoryMmap:
+	default:
+		invalid = append(invalid, fmt.Sprintf("unknown repository %q", c.Repository.Type))
+	}
+	check(c.Repository.Type == RepositoryMemory || fits32, "%s repository only supports up to %d digits", c.Repository.Type, maxDigits32)
+
+	check(c.Snapshot.Interval >= 0, "snapshot interval cannot be negative")
+	check(c.Snapshot.Path == "" || fits32, "snapshots only support up to %d digits", maxDigits32)
+
+	check(c.Epochs.Interval >= 0, "epoch cannot be negative")
+	if c.Epochs.Interval > 0 {
+		check(c.Repository.Type == RepositoryMemory, "epochs are only supported by memory repository")
+		check(c.Snapshot.Path == "", "epochs are not supported with snapshots")
+		check(fits32, "epochs only support up to %d digits", maxDigits32)
+	}
+
+	if len(invalid) > 0 {
+		return errors.New(strings.Join(invalid, ", "))
+	}
+
+	return nil
+}
+
+// keepsNumbers returns true if numbers of previous runs are recovered, so log is appended instead of truncated
+func (c Config) keepsNumbers() bool {
+	return c.Repository.Type == RepositoryDisk || c.Repository.Type == RepositoryMmap || c.Snapshot.Path != "" || c.WALDir != ""
+}
+
+// repositoryPath returns the file storing numbers of on-disk repositories
+func (c Config) repositoryPath() string {
+	if c.Repository.Path != "" {
+		return c.Repository.Path
+	}
+
+	return c.LogPath + ".bitmap"
+}
+
+// NewConfig creates a config with default values for given port and log path
+func NewConfig(port int, logPath string) *Config {
+	return &Config{
+		Port:                port,
+		LogPath:             logPath,
+		LogFlushBatchSize:   DefaultLogFlushBatchSize,
+		LogFlushInterval:    DefaultLogFlushInterval,
+		ReportFlushInterval: DefaultReportFlushInterval,
+		ConcurrentClients:   DefaultConcurrentClients,
+		LineFormat:          line.DefaultFormat,
+		Repository: RepositoryConfig{
+			Type:                   RepositoryMemory,
+			BloomCapacity:          DefaultBloomCapacity,
+			BloomFalsePositiveRate: DefaultBloomFalsePositiveRate,
+			TTL:                    DefaultTTL,
+			TTLBuckets:             DefaultTTLBuckets,
+		},
+		ReportFile: ReportFileConfig{
+			MaxSize: DefaultReportFileMaxSize,
+			Backups: DefaultReportFileBackups,
+		},
+		Snapshot: SnapshotConfig{
+			Interval: DefaultSnapshotInterval,
+		},
+		Logging: LoggingConfig{
+			Level:  DefaultLoggingLevel,
+			Format: DefaultLoggingFormat,
+		},
+	}
+}
+
+// listenAddresses returns the addresses to listen on, Port if none configured and portListened
+func (c Config) listenAddresses(portListened bool) (addresses []string) {
+	addresses = append(addresses, c.ListenAddresses...)
+	if len(addresses) == 0 && portListened {
+		addresses = []string{fmt.Sprintf(":%d", c.Port)}
+	}
+
+	if c.UnixSocket.Path != "" {
+		addresses = append(addresses, "unix:"+c.UnixSocket.Path)
+	}
+
+	return
 }
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_ValidateDefaults(t *testing.T) {
+	assert.NoError(t, NewConfig(DefaultPort, DefaultLogFile).Validate())
+}
+
+func TestConfig_ValidateListsEveryInvalidSetting(t *testing.T) {
+	config := NewConfig(70000, DefaultLogFile)
+	config.ConcurrentClients = 0
+	config.TLS.KeyFile = "server.key"
+	config.Repository.Type = "redis"
+
+	err := config.Validate()
+
+	assert.Error(t, err)
+	for _, setting := range []string{"port", "concurrent clients", "tls key", "redis"} {
+		assert.Contains(t, err.Error(), setting)
+	}
+}
+
+func TestConfig_ValidateRejectsUnsupportedCombinations(t *testing.T) {
+	for name, configure := range map[string]func(c *Config){
+		"bloom with 64-bit numbers": func(c *Config) {
+			c.Repository.Type = RepositoryBloom
+			c.LineFormat.Digits = 12
+		},
+		"epochs on ttl repository": func(c *Config) {
+			c.Repository.Type = RepositoryTTL
+			c.Epochs.Interval = time.Hour
+		},
+		"epochs with snapshots": func(c *Config) {
+			c.Snapshot.Path = "numbers.snapshot"
+			c.Epochs.Interval = time.Hour
+		},
+		"unknown log format": func(c *Config) {
+			c.Logging.Format = "xml"
+		},
+		"unknown log level": func(c *Config) {
+			c.Logging.Level = "verbose"
+		},
+		"invalid bloom rate": func(c *Config) {
+			c.Repository.Type = RepositoryBloom
+			c.Repository.BloomFalsePositiveRate = 1
+		},
+	} {
+		config := NewConfig(DefaultPort, DefaultLogFile)
+		configure(config)
+
+		assert.Error(t, config.Validate(), name)
+	}
+}
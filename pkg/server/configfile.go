@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ConfigEnvPrefix prefix of the environment variables overriding settings, e.g: NUMSERVER_CONCURRENT_CLIENTS
+const ConfigEnvPrefix = "NUMSERVER_"
+
+// redacted printed instead of secret settings, which should be set by hand on printed config files
+const redacted = "***"
+
+// configSetting config field named as its flag, with underscores instead of dashes
+type configSetting struct {
+	name  string
+	value configValue
+}
+
+// configSettings returns every setting of given config
+func configSettings(c *Config) []configSetting {
+	return []configSetting{
+		{"port", intValue{&c.Port}},
+		{"listen", listValue{&c.ListenAddresses}},
+		{"file", stringValue{&c.LogPath}},
+		{"http", stringValue{&c.HTTPAddress}},
+		{"query", stringValue{&c.QueryAddress}},
+		{"udp", stringValue{&c.UDPAddress}},
+		// line format
+		{"newline", newlineValue{&c.LineFormat.Newline}},
+		{"digits", intValue{&c.LineFormat.Digits}},
+		{"min", uint64Value{&c.LineFormat.Min}},
+		{"max", uint64Value{&c.LineFormat.Max}},
+		// unix socket
+		{"unix_socket", stringValue{&c.UnixSocket.Path}},
+		{"unix_socket_mode", fileModeValue{&c.UnixSocket.Mode}},
+		{"unix_socket_user", stringValue{&c.UnixSocket.User}},
+		{"unix_socket_group", stringValue{&c.UnixSocket.Group}},
+		// intervals and limits
+		{"report_interval", durationValue{&c.ReportFlushInterval}},
+		{"log_flush_interval", durationValue{&c.LogFlushInterval}},
+		{"log_flush_batch_size", intValue{&c.LogFlushBatchSize}},
+		{"concurrent_clients", intValue{&c.ConcurrentClients}},
+		{"idle_timeout", durationValue{&c.IdleTimeout}},
+		// report file
+		{"report_file", stringValue{&c.ReportFile.Path}},
+		{"report_file_max_size", intValue{&c.ReportFile.MaxSize}},
+		{"report_file_backups", intValue{&c.ReportFile.Backups}},
+		// tls
+		{"tls_cert", stringValue{&c.TLS.CertFile}},
+		{"tls_key", stringValue{&c.TLS.KeyFile}},
+		{"tls_client_ca", stringValue{&c.TLS.ClientCAFile}},
+		// connection access
+		{"allow_cidrs", networksValue{&c.Access.Allow}},
+		{"deny_cidrs", networksValue{&c.Access.Deny}},
+		{"max_conns_per_ip", intValue{&c.Access.MaxConnsPerIP}},
+		// termination authorization
+		{"terminate_disabled", boolValue{&c.Termination.Disabled}},
+		{"terminate_cidrs", networksValue{&c.Termination.Networks}},
+		{"terminate_token", secretValue{&c.Termination.Token}},
+		// repository
+		{"repository", stringValue{&c.Repository.Type}},
+		{"repository_path", stringValue{&c.Repository.Path}},
+		{"bloom_capacity", intValue{&c.Repository.BloomCapacity}},
+		{"bloom_fp_rate", floatValue{&c.Repository.BloomFalsePositiveRate}},
+		{"ttl", durationValue{&c.Repository.TTL}},
+		{"ttl_buckets", intValue{&c.Repository.TTLBuckets}},
+		// persistence
+		{"snapshot", stringValue{&c.Snapshot.Path}},
+		{"snapshot_interval", durationValue{&c.Snapshot.Interval}},
+		{"epoch", durationValue{&c.Epochs.Interval}},
+		{"wal", stringValue{&c.WALDir}},
+		// server logs
+		{"log_level", stringValue{&c.Logging.Level}},
+		{"log_format", logFormatValue{&c.Logging.Format}},
+	}
+}
+
+// LoadConfigFile applies the settings on given json file over config, the ones missing keep their value
+// settings are named as flags with underscores, e.g: {"report_interval": "10s", "allow_cidrs": ["10.0.0.0/8"]}
+// unknown settings are rejected
+func LoadConfigFile(path string, c *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "cannot open config file")
+	}
+	defer file.Close()
+
+	var settings map[string]json.RawMessage
+	err = json.NewDecoder(file).Decode(&settings)
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse config file %s", path)
+	}
+
+	known := make(map[string]configValue)
+	for _, s := range configSettings(c) {
+		known[s.name] = s.value
+	}
+
+	for name, raw := range settings {
+		value, ok := known[name]
+		if !ok {
+			return errors.Errorf("unknown setting %s on config file %s", name, path)
+		}
+
+		text, err := rawText(raw)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s on config file %s", name, path)
+		}
+
+		err = value.Set(text)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s on config file %s", name, path)
+		}
+	}
+
+	return nil
+}
+
+// rawText returns the text of a json string, number, bool or array of strings, joined by commas
+func rawText(raw json.RawMessage) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var v interface{}
+	err := decoder.Decode(&v)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", errors.Errorf("expected a list of strings, got %s", raw)
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+
+	return "", errors.Errorf("unexpected value %s", raw)
+}
+
+// LoadConfigEnv applies over config the settings given on environment, named as on config file
+// uppercased with ConfigEnvPrefix, e.g: NUMSERVER_ALLOW_CIDRS=10.0.0.0/8,192.168.0.0/16
+func LoadConfigEnv(c *Config) error {
+	return loadConfigEnv(c, os.LookupEnv)
+}
+
+func loadConfigEnv(c *Config, lookupEnv func(string) (string, bool)) error {
+	for _, s := range configSettings(c) {
+		name := ConfigEnvPrefix + strings.ToUpper(s.name)
+
+		text, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		err := s.value.Set(text)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s", name)
+		}
+	}
+
+	return nil
+}
+
+// WriteConfig writes every setting of given config as a json config file, redacting secrets as the token
+func WriteConfig(w io.Writer, c Config) error {
+	settings := make(map[string]interface{})
+	for _, s := range configSettings(&c) {
+		settings[s.name] = s.value.Get()
+	}
+
+	content, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(content, '\n'))
+
+	return err
+}
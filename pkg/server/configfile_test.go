@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigFile_KeepsMissingSettings(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "numserver.json")
+	content := `{"report_interval": "10s", "concurrent_clients": 10, "idle_timeout": "5m", "deny_cidrs": ["10.0.1.0/24"]}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+	config := NewConfig(DefaultPort, DefaultLogFile)
+	assert.NoError(t, LoadConfigFile(path, config))
+
+	assert.Equal(t, 10*time.Second, config.ReportFlushInterval)
+	assert.Equal(t, 10, config.ConcurrentClients)
+	assert.Equal(t, 5*time.Minute, config.IdleTimeout)
+	assert.Len(t, config.Access.Deny, 1)
+	assert.Equal(t, DefaultLogFlushInterval, config.LogFlushInterval)
+	assert.Equal(t, DefaultLogFlushBatchSize, config.LogFlushBatchSize)
+	assert.Nil(t, config.Access.Allow)
+}
+
+func TestLoadConfigFile_FailsOnInvalidSettings(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, content := range []string{
+		`{"report_interval": 10}`,
+		`{"report_interval": "10 seconds"}`,
+		`{"allow_cidrs": ["10.0.0.0"]}`,
+		`not json`,
+	} {
+		path := filepath.Join(dir, "numserver.json")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+		assert.Error(t, LoadConfigFile(path, NewConfig(DefaultPort, DefaultLogFile)), content)
+	}
+}
+
+func TestLoadConfigFile_FailsOnUnknownSettings(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "numserver.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"concurrent_client": 10}`), 0644))
+
+	err := LoadConfigFile(path, NewConfig(DefaultPort, DefaultLogFile))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "concurrent_client")
+}
+
+func TestLoadConfigEnv_OverridesSettings(t *testing.T) {
+	env := map[string]string{
+		"NUMSERVER_CONCURRENT_CLIENTS": "10",
+		"NUMSERVER_LISTEN":             "127.0.0.1:4000,unix:/tmp/numserver.sock",
+		"NUMSERVER_TERMINATE_DISABLED": "true",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	config := NewConfig(DefaultPort, DefaultLogFile)
+	assert.NoError(t, loadConfigEnv(config, lookupEnv))
+
+	assert.Equal(t, 10, config.ConcurrentClients)
+	assert.Equal(t, []string{"127.0.0.1:4000", "unix:/tmp/numserver.sock"}, config.ListenAddresses)
+	assert.True(t, config.Termination.Disabled)
+	assert.Equal(t, DefaultPort, config.Port)
+
+	env["NUMSERVER_CONCURRENT_CLIENTS"] = "ten"
+	assert.Error(t, loadConfigEnv(config, lookupEnv))
+}
+
+func TestWriteConfig_IsLoadable(t *testing.T) {
+	dir := t.TempDir()
+
+	config := NewConfig(DefaultPort, DefaultLogFile)
+	config.ListenAddresses = []string{"127.0.0.1:4000"}
+	config.UnixSocket.Mode = 0660
+	config.IdleTimeout = 5 * time.Minute
+	config.Repository.Type = RepositoryTTL
+	assert.NoError(t, loadConfigEnv(config, func(name string) (string, bool) {
+		return "10.0.0.0/8", name == "NUMSERVER_ALLOW_CIDRS"
+	}))
+
+	path := filepath.Join(dir, "numserver.json")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	assert.NoError(t, WriteConfig(file, *config))
+	assert.NoError(t, file.Close())
+
+	loaded := NewConfig(0, "")
+	assert.NoError(t, LoadConfigFile(path, loaded))
+
+	assert.Equal(t, config, loaded)
+}
+
+func TestWriteConfig_RedactsSecrets(t *testing.T) {
+	config := NewConfig(DefaultPort, DefaultLogFile)
+	config.Termination.Token = "s3cr3t"
+
+	var output bytes.Buffer
+	assert.NoError(t, WriteConfig(&output, *config))
+
+	assert.NotContains(t, output.String(), "s3cr3t")
+	assert.Contains(t, output.String(), `"terminate_token": "***"`)
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/line"
+)
+
+// configValue config field settable from its text, as given by config file or environment
+type configValue interface {
+	Set(text string) error
+	// Get returns the value as written on config files
+	Get() interface{}
+}
+
+type stringValue struct{ v *string }
+
+func (s stringValue) Set(text string) error { *s.v = text; return nil }
+func (s stringValue) Get() interface{}      { return *s.v }
+
+// secretValue string setting printed redacted if set, e.g. tokens
+type secretValue struct{ v *string }
+
+func (s secretValue) Set(text string) error { *s.v = text; return nil }
+func (s secretValue) Get() interface{} {
+	if *s.v == "" {
+		return ""
+	}
+	return redacted
+}
+
+type intValue struct{ v *int }
+
+func (i intValue) Set(text string) (err error) {
+	*i.v, err = strconv.Atoi(text)
+	return
+}
+func (i intValue) Get() interface{} { return *i.v }
+
+type uint64Value struct{ v *uint64 }
+
+func (u uint64Value) Set(text string) (err error) {
+	*u.v, err = strconv.ParseUint(text, 10, 64)
+	return
+}
+func (u uint64Value) Get() interface{} { return *u.v }
+
+type floatValue struct{ v *float64 }
+
+func (f floatValue) Set(text string) (err error) {
+	*f.v, err = strconv.ParseFloat(text, 64)
+	return
+}
+func (f floatValue) Get() interface{} { return *f.v }
+
+type boolValue struct{ v *bool }
+
+func (b boolValue) Set(text string) (err error) {
+	*b.v, err = strconv.ParseBool(text)
+	return
+}
+func (b boolValue) Get() interface{} { return *b.v }
+
+// durationValue duration like "1m30s"
+type durationValue struct{ v *time.Duration }
+
+func (d durationValue) Set(text string) (err error) {
+	*d.v, err = time.ParseDuration(text)
+	return
+}
+func (d durationValue) Get() interface{} { return d.v.String() }
+
+// listValue comma separated list
+type listValue struct{ v *[]string }
+
+func (l listValue) Set(text string) error {
+	*l.v = nil
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l.v = append(*l.v, item)
+		}
+	}
+	return nil
+}
+func (l listValue) Get() interface{} { return append([]string{}, *l.v...) }
+
+// networksValue comma separated list of cidrs
+type networksValue struct{ v *[]*net.IPNet }
+
+func (n networksValue) Set(text string) (err error) {
+	*n.v, err = line.ParseNetworks(strings.Split(text, ","))
+	return
+}
+func (n networksValue) Get() interface{} {
+	cidrs := []string{}
+	for _, network := range *n.v {
+		cidrs = append(cidrs, network.String())
+	}
+	return cidrs
+}
+
+// fileModeValue octal file mode like "0660"
+type fileModeValue struct{ v *os.FileMode }
+
+func (m fileModeValue) Set(text string) error {
+	mode, err := strconv.ParseUint(text, 8, 32)
+	*m.v = os.FileMode(mode)
+	return err
+}
+func (m fileModeValue) Get() interface{} { return fmt.Sprintf("%04o", uint32(*m.v)) }
+
+type newlineValue struct{ v *line.Newline }
+
+func (n newlineValue) Set(text string) error { *n.v = line.Newline(text); return nil }
+func (n newlineValue) Get() interface{}      { return string(*n.v) }
+
+type logFormatValue struct{ v *errhandler.Format }
+
+func (f logFormatValue) Set(text string) error { *f.v = errhandler.Format(text); return nil }
+func (f logFormatValue) Get() interface{}      { return string(*f.v) }
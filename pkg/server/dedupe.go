@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/varas/numserver/pkg/line"
+)
+
+// protocolDedupe per line replies protocol, negotiated by "hello dedupe" first line
+// server replies "hello dedupe" and then a line per line received: U unique, D duplicate or E invalid
+const protocolDedupe = "dedupe"
+
+// dedupeWriter buffers replies, flushing them once all buffered input is processed to keep throughput
+type dedupeWriter struct {
+	output *bufio.Writer
+	input  *line.Reader
+	err    error
+}
+
+func newDedupeWriter(output io.Writer, input *line.Reader) *dedupeWriter {
+	return &dedupeWriter{
+		output: bufio.NewWriter(output),
+		input:  input,
+	}
+}
+
+// processed replies a line result, once a write fails the following replies are discarded
+func (d *dedupeWriter) processed(result lineResult) {
+	if d.err != nil {
+		return
+	}
+
+	_, d.err = d.output.Write([]byte{byte(result), '\n'})
+
+	// next read may block, so client should get its replies before
+	if d.err == nil && d.input.Buffered() == 0 {
+		d.err = d.output.Flush()
+	}
+}
+
+// close flushes pending replies returning the first write error if any
+func (d *dedupeWriter) close() error {
+	if d.err != nil {
+		return d.err
+	}
+
+	return d.output.Flush()
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/errhandler"
+)
+
+func TestNumServer_RepliesDedupeResultPerLine(t *testing.T) {
+	port := runServer(errhandler.Noop)
+
+	client, err := net.Dial("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	defer client.Close()
+
+	reader := bufio.NewReader(client)
+
+	_, err = client.Write([]byte("hello dedupe\n123456781\n"))
+	assert.NoError(t, err)
+
+	hello, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "hello dedupe\n", hello)
+
+	reply, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "U\n", reply, "reply should be flushed before waiting for more input")
+
+	_, err = client.Write([]byte("123456781\nshort\n123456782\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.(*net.TCPConn).CloseWrite())
+
+	replies, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "D\nE\nU\n", string(replies))
+}
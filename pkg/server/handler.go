@@ -3,53 +3,100 @@ package server
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"net"
+	"sync/atomic"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/varas/numserver/pkg/errhandler"
 	"github.com/varas/numserver/pkg/line"
 	"github.com/varas/numserver/pkg/report"
 	"github.com/varas/numserver/pkg/repository"
+	"github.com/varas/numserver/pkg/result"
+	"github.com/varas/numserver/pkg/wal"
 )
 
+// lineResult outcome of processing a line, as replied on dedupe protocol
+type lineResult byte
+
+const (
+	lineUnique    lineResult = 'U'
+	lineDuplicate lineResult = 'D'
+	lineInvalid   lineResult = 'E'
+)
+
+// tlsHandshakeTimeout closes clients not completing the handshake, as idle timeout may be disabled
+const tlsHandshakeTimeout = 10 * time.Second
+
 type connHandler struct {
-	errHandle        errhandler.ErrHandler
-	lineValidator    *line.Validator
-	numberRepository repository.NumberRepository
-	report           *report.Report
-	conns            <-chan net.Conn
-	terminate        chan struct{}
+	errHandle     errhandler.ErrHandler
+	lineValidator *line.Validator
+	numberSet     repository.NumberSet
+	wal           *wal.Log // nil if disabled
+	commits       *result.Commits
+	report        *report.Report
+	termination   line.TerminationPolicy
+	slots         *clientSlots
+	conns         <-chan net.Conn
+	terminate     chan struct{}
+	// nanoseconds without input before closing a connection, 0 disabled, atomic as changed while running
+	idleTimeout int64
+	// id of the last connection handled, atomic as shared by handler pool
+	lastConnID uint64
 }
 
 func newConnHandler(
 	errHandle errhandler.ErrHandler,
 	lineValidator *line.Validator,
-	numberRepo repository.NumberRepository,
+	numberSet repository.NumberSet,
+	wal *wal.Log,
+	commits *result.Commits,
 	report *report.Report,
+	termination line.TerminationPolicy,
+	slots *clientSlots,
 	conns <-chan net.Conn,
 	terminate chan struct{},
 ) *connHandler {
 	return &connHandler{
-		errHandle:        errHandle,
-		lineValidator:    lineValidator,
-		numberRepository: numberRepo,
-		report:           report,
-		conns:            conns,
-		terminate:        terminate,
+		errHandle:     errHandle,
+		lineValidator: lineValidator,
+		numberSet:     numberSet,
+		wal:           wal,
+		commits:       commits,
+		report:        report,
+		termination:   termination,
+		slots:         slots,
+		conns:         conns,
+		terminate:     terminate,
 	}
 }
 
-func (r *connHandler) run(ctx context.Context) {
+// setIdleTimeout changes how long connections can be waiting for input, applied on their next read
+func (r *connHandler) setIdleTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&r.idleTimeout, int64(timeout))
+}
+
+// run handles connections until context is done or a value is received from quit
+func (r *connHandler) run(ctx context.Context, quit <-chan struct{}) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
+		case <-quit:
+			return
+
 		case c, open := <-r.conns:
 			if !open {
 				return
 			}
+			// slots are shared with other ingestion paths, an accepted conn is always handled
+			r.slots.acquire(context.Background())
 			r.handle(ctx, c)
+			r.slots.release()
 		}
 	}
 }
@@ -59,23 +106,194 @@ func (r *connHandler) handle(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 	reader := line.NewReader(*bufio.NewReader(conn), r.lineValidator)
 
+	// errors are logged along the connection they come from
+	id := atomic.AddUint64(&r.lastConnID, 1)
+	errHandle := func(err error) {
+		r.errHandle(errhandler.WithFields(err, "conn", id, "remote", conn.RemoteAddr().String()))
+	}
+
+	if tlsConn, ok := asTLS(conn); ok {
+		err := handshake(tlsConn)
+		if err != nil {
+			errHandle(err)
+			return
+		}
+	}
+
+	r.extendDeadline(conn, errHandle)
+	protocol, err := reader.ReadHello()
+	if err != nil && !isTimeout(err) {
+		errHandle(err)
+	}
+	// connection is unusable, e.g. closed by client
+	if line.IsReadError(err) {
+		return
+	}
+
+	switch protocol {
+	case protocolAck:
+		r.handleAcked(ctx, conn, reader, errHandle)
+	case protocolDedupe:
+		r.handleDedupe(conn, reader, errHandle)
+	case "":
+		r.readNumbers(conn, reader, errHandle, func(lineResult) {})
+	default:
+		// unknown protocols are handled as invalid input
+		errHandle(errors.Errorf("unknown protocol %s from %s", protocol, conn.RemoteAddr()))
+		r.readNumbers(conn, reader, errHandle, func(lineResult) {})
+	}
+}
+
+// handleAcked acks lines once durable, context is only used to stop waiting for acks
+func (r *connHandler) handleAcked(ctx context.Context, conn net.Conn, reader *line.Reader, errHandle errhandler.ErrHandler) {
+	_, err := fmt.Fprintf(conn, "hello %s\n", protocolAck)
+	if err != nil {
+		errHandle(errors.Wrap(err, "cannot reply hello"))
+		return
+	}
+
+	acks := newAckWriter(r.commits, conn)
+	acked := make(chan struct{})
+
+	go func() {
+		err := acks.run(ctx)
+		if err != nil {
+			errHandle(errors.Wrap(err, "cannot write ack"))
+		}
+		close(acked)
+	}()
+
+	r.readNumbers(conn, reader, errHandle, acks.processed)
+
+	acks.close()
+	<-acked
+}
+
+// handleDedupe replies whether each line was unique, duplicate or invalid
+func (r *connHandler) handleDedupe(conn net.Conn, reader *line.Reader, errHandle errhandler.ErrHandler) {
+	_, err := fmt.Fprintf(conn, "hello %s\n", protocolDedupe)
+	if err != nil {
+		errHandle(errors.Wrap(err, "cannot reply hello"))
+		return
+	}
+
+	replies := newDedupeWriter(conn, reader)
+
+	r.readNumbers(conn, reader, errHandle, replies.processed)
+
+	err = replies.close()
+	if err != nil {
+		errHandle(errors.Wrap(err, "cannot write dedupe reply"))
+	}
+}
+
+// readNumbers reads lines until input end or termination, calling processed after each one
+func (r *connHandler) readNumbers(conn net.Conn, reader *line.Reader, errHandle errhandler.ErrHandler, processed func(lineResult)) {
 	for {
-		num, err := reader.ReadNumberLine()
+		if reader.Buffered() == 0 {
+			// numbers read are durable before waiting for more input, grouped with other connections
+			if r.wal != nil {
+				err := r.wal.Sync()
+				if err != nil {
+					errHandle(err)
+				}
+			}
+
+			r.extendDeadline(conn, errHandle)
+		}
+
+		num, err := reader.ReadNumberLine64()
 		if err == io.EOF {
 			return
 		}
 
+		// idle clients are disconnected without comment
+		if isTimeout(err) {
+			return
+		}
+
 		if err == line.ErrTermination {
+			authErr := r.termination.Authorize(conn.RemoteAddr(), reader.TerminationToken())
+			if authErr != nil {
+				// unauthorized termination is handled as any other invalid input
+				errHandle(errors.Wrapf(authErr, "rejected termination from %s", conn.RemoteAddr()))
+				processed(lineInvalid)
+				continue
+			}
+
 			close(r.terminate)
 			return
 		}
 
+		// further reads fail the same way, e.g. a broken tls session
+		if line.IsReadError(err) {
+			errHandle(err)
+			return
+		}
+
 		if err != nil {
-			r.errHandle(err)
+			errHandle(err)
+			processed(lineInvalid)
 			continue
 		}
 
-		unique := r.numberRepository.AddNumber(num)
+		unique := r.numberSet.Add(num)
 		r.report.Increase(unique)
+
+		if unique && r.wal != nil {
+			err = r.wal.Append(num)
+			if err != nil {
+				errHandle(err)
+			}
+		}
+
+		if unique {
+			processed(lineUnique)
+		} else {
+			processed(lineDuplicate)
+		}
 	}
 }
+
+// extendDeadline sets the deadline of the next read from conn, removing it if idle timeout is disabled
+func (r *connHandler) extendDeadline(conn net.Conn, errHandle errhandler.ErrHandler) {
+	var deadline time.Time
+	if timeout := time.Duration(atomic.LoadInt64(&r.idleTimeout)); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	err := conn.SetReadDeadline(deadline)
+	if err != nil {
+		errHandle(errors.Wrapf(err, "cannot set read deadline of %s", conn.RemoteAddr()))
+	}
+}
+
+// asTLS returns the tls connection of conn, if any, including ones limited per ip
+func asTLS(conn net.Conn) (*tls.Conn, bool) {
+	if releasing, ok := conn.(*releasingConn); ok {
+		conn = releasing.Conn
+	}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	return tlsConn, ok
+}
+
+// handshake completes the tls handshake within tlsHandshakeTimeout, so failures are not read as input
+func handshake(conn *tls.Conn) error {
+	err := conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err != nil {
+		return errors.Wrapf(err, "cannot set handshake deadline of %s", conn.RemoteAddr())
+	}
+
+	err = conn.Handshake()
+	if err != nil {
+		return errors.Wrapf(err, "tls handshake failed with %s", conn.RemoteAddr())
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := errors.Cause(err).(net.Error)
+	return ok && netErr.Timeout()
+}
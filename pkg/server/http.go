@@ -0,0 +1,312 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/line"
+	"github.com/varas/numserver/pkg/report"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+// max integer exactly represented by a json number (float64)
+const maxExactFloat = 1 << 53
+
+// http limits, as slow or oversized requests hold client slots
+const (
+	httpReadHeaderTimeout = 10 * time.Second
+	httpReadTimeout       = time.Minute
+	httpIdleTimeout       = 2 * time.Minute
+	httpMaxBodySize       = 10 << 20
+	// in-flight requests are interrupted after it on stop
+	httpShutdownTimeout = 10 * time.Second
+)
+
+// HTTPResult counts of numbers received on a request
+type HTTPResult struct {
+	Unique    uint `json:"unique"`
+	Duplicate uint `json:"duplicate"`
+	Invalid   uint `json:"invalid"`
+}
+
+// HTTPListener serves POST /numbers, sharing client slots and repository with tcp clients
+// body is the same newline format as tcp, or a json array of numbers or number strings on application/json
+type HTTPListener struct {
+	listener      net.Listener
+	server        *http.Server
+	access        *accessControl
+	errHandle     errhandler.ErrHandler
+	lineValidator *line.Validator
+	numberSet     repository.NumberSet
+	report        *report.Report
+	slots         *clientSlots
+	maxBodySize   int64
+	requests      *inFlightRequests
+	stopOnce      sync.Once
+	stopped       chan struct{}
+}
+
+// inFlightRequests tracks requests adding numbers, so stop can wait for them even if interrupted
+type inFlightRequests struct {
+	wg       sync.WaitGroup
+	stopping bool
+	sync.Mutex
+}
+
+// start returns false if stopping, otherwise the request is tracked until done is called
+func (f *inFlightRequests) start() bool {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.stopping {
+		return false
+	}
+	f.wg.Add(1)
+
+	return true
+}
+
+func (f *inFlightRequests) done() {
+	f.wg.Done()
+}
+
+// stop rejects new requests and waits for the tracked ones
+func (f *inFlightRequests) stop() {
+	f.Lock()
+	f.stopping = true
+	f.Unlock()
+
+	f.wg.Wait()
+}
+
+// NewHTTPListener creates a new http listener on given tcp address, served over tls if tlsConfig is given
+func NewHTTPListener(
+	address string,
+	tlsConfig *tls.Config,
+	access AccessPolicy,
+	errHandle errhandler.ErrHandler,
+	lineValidator *line.Validator,
+	numberSet repository.NumberSet,
+	report *report.Report,
+	slots *clientSlots,
+) (*HTTPListener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot listen on socket tcp/%s", address)
+	}
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	s := &HTTPListener{
+		listener:      listener,
+		access:        newAccessControl(access),
+		errHandle:     errHandle,
+		lineValidator: lineValidator,
+		numberSet:     numberSet,
+		report:        report,
+		slots:         slots,
+		maxBodySize:   httpMaxBodySize,
+		requests:      &inFlightRequests{},
+		stopped:       make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/numbers", s.handleNumbers)
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		IdleTimeout:       httpIdleTimeout,
+	}
+
+	return s, nil
+}
+
+// Listen serves http requests until stopped, returning once in-flight requests are done
+func (s *HTTPListener) Listen(ctx context.Context) error {
+	go s.waitForContextTermination(ctx)
+
+	err := s.server.Serve(s.listener)
+	if err == http.ErrServerClosed {
+		// serving ends as soon as stop starts
+		<-s.stopped
+	}
+
+	return err
+}
+
+// Stop stops the listener waiting for in-flight requests to avoid data loss,
+// the ones taking longer than httpShutdownTimeout are interrupted
+func (s *HTTPListener) Stop() {
+	s.stopOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+
+		err := s.server.Shutdown(ctx)
+		if err != nil {
+			_ = s.server.Close()
+		}
+
+		// interrupted requests may still be adding numbers
+		s.requests.stop()
+		close(s.stopped)
+	})
+}
+
+// SetAccess changes the access policy applied from now on, already accepted clients are kept
+func (s *HTTPListener) SetAccess(access AccessPolicy) {
+	s.access.setPolicy(access)
+}
+
+func (s *HTTPListener) waitForContextTermination(ctx context.Context) {
+	<-ctx.Done()
+	s.Stop()
+}
+
+func (s *HTTPListener) handleNumbers(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	host, _, _ := net.SplitHostPort(req.RemoteAddr)
+	if ip := net.ParseIP(host); ip != nil && !s.access.isAllowed(ip) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if !s.requests.start() {
+		http.Error(w, "server stopping", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.requests.done()
+
+	if !s.slots.acquire(req.Context()) {
+		http.Error(w, "no client slot available", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.slots.release()
+
+	// numbers added before exceeding it are kept
+	body := http.MaxBytesReader(w, req.Body, s.maxBodySize)
+
+	var result HTTPResult
+	var err error
+	if isJSON(req.Header.Get("Content-Type")) {
+		result, err = s.addJSONNumbers(body)
+	} else {
+		result, err = s.addNumberLines(body)
+	}
+
+	var tooLarge *http.MaxBytesError
+	if stderrors.As(err, &tooLarge) {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		s.errHandle(errhandler.WithFields(errors.Wrap(err, "cannot write http response"), "remote", req.RemoteAddr))
+	}
+}
+
+// addNumberLines adds lines as tcp clients do, termination lines are invalid over http
+// returns error only if body cannot be read
+func (s *HTTPListener) addNumberLines(body io.Reader) (result HTTPResult, err error) {
+	reader := line.NewReader(*bufio.NewReader(body), s.lineValidator)
+
+	for {
+		num, err := reader.ReadNumberLine64()
+		if err == io.EOF {
+			return result, nil
+		}
+
+		if line.IsReadError(err) {
+			return result, err
+		}
+
+		if err != nil {
+			result.Invalid++
+			continue
+		}
+
+		s.add(num, &result)
+	}
+}
+
+// addJSONNumbers adds a json array of numbers or number strings, being invalid any other item
+func (s *HTTPListener) addJSONNumbers(body io.Reader) (result HTTPResult, err error) {
+	var items []interface{}
+	err = json.NewDecoder(body).Decode(&items)
+	if err != nil {
+		return result, errors.Wrap(err, "invalid json array")
+	}
+
+	for _, item := range items {
+		num, valid := s.parseJSONNumber(item)
+		if !valid {
+			result.Invalid++
+			continue
+		}
+
+		s.add(num, &result)
+	}
+
+	return
+}
+
+func (s *HTTPListener) parseJSONNumber(item interface{}) (uint64, bool) {
+	switch n := item.(type) {
+	case float64:
+		// larger numbers lose precision as json numbers, so should be sent as strings
+		if n < 0 || n > maxExactFloat || n != float64(uint64(n)) || !s.lineValidator.InRange(uint64(n)) {
+			return 0, false
+		}
+		return uint64(n), true
+
+	case string:
+		number, err := s.lineValidator.ParseNumber(n)
+		if err != nil {
+			return 0, false
+		}
+		return number, true
+	}
+
+	return 0, false
+}
+
+func (s *HTTPListener) add(num uint64, result *HTTPResult) {
+	unique := s.numberSet.Add(num)
+	s.report.Increase(unique)
+
+	if unique {
+		result.Unique++
+	} else {
+		result.Duplicate++
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && mediaType == "application/json"
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/line"
+	"github.com/varas/numserver/pkg/report"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+func TestHTTPListener_AddsNumberLines(t *testing.T) {
+	l := newTestHTTPListener(t, newClientSlots(1))
+
+	rec := postNumbers(l, "text/plain", "007007009\n314159265\n007007009\nshort\n")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, HTTPResult{Unique: 2, Duplicate: 1, Invalid: 1}, decodeResult(t, rec))
+}
+
+func TestHTTPListener_AddsJSONNumbers(t *testing.T) {
+	l := newTestHTTPListener(t, newClientSlots(1))
+
+	rec := postNumbers(l, "application/json; charset=utf-8", `["007007009", 7007009, 314159265, "short", 1234567890, 1.5, "terminate"]`)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, HTTPResult{Unique: 2, Duplicate: 1, Invalid: 4}, decodeResult(t, rec))
+}
+
+func TestHTTPListener_RejectsInvalidRequests(t *testing.T) {
+	l := newTestHTTPListener(t, newClientSlots(1))
+
+	rec := postNumbers(l, "application/json", `{"not": "an array"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	l.handleNumbers(rec, httptest.NewRequest(http.MethodGet, "/numbers", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHTTPListener_SharesClientSlots(t *testing.T) {
+	slots := newClientSlots(1)
+	l := newTestHTTPListener(t, slots)
+
+	// slot taken by a tcp client
+	slots.acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/numbers", strings.NewReader(validOneLineInput)).WithContext(ctx)
+	l.handleNumbers(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "request should not be handled while no slot is free")
+
+	slots.release()
+
+	rec = postNumbers(l, "text/plain", validOneLineInput)
+	assert.Equal(t, HTTPResult{Unique: 1}, decodeResult(t, rec))
+}
+
+func TestHTTPListener_RejectsTooLargeBodies(t *testing.T) {
+	l := newTestHTTPListener(t, newClientSlots(1))
+	l.maxBodySize = 100
+
+	rec := postNumbers(l, "text/plain", strings.Repeat(validOneLineInput, 11))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = postNumbers(l, "application/json", "["+strings.Repeat(`"314159265",`, 10)+`"314159265"]`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestHTTPListener_StopWaitsForInFlightRequests(t *testing.T) {
+	validator, err := line.NewValidator()
+	assert.NoError(t, err)
+
+	numberRepository := repository.NewInMemoryRepository()
+	l, err := NewHTTPListener("127.0.0.1:0", nil, AccessPolicy{}, errhandler.Noop, validator,
+		repository.NewNumberSet(numberRepository), &report.Report{}, newClientSlots(1))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	listened := make(chan error)
+	go func() {
+		listened <- l.Listen(ctx)
+	}()
+
+	body, input := io.Pipe()
+	go func() {
+		resp, err := http.Post("http://"+l.listener.Addr().String()+"/numbers", "text/plain", body)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+
+	_, err = input.Write([]byte("000000001\n"))
+	assert.NoError(t, err)
+
+	// request being handled
+	deadline := time.Now().Add(2 * time.Second)
+	for !numberRepository.Contains(1) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case <-listened:
+		t.Fatal("listen should wait for in-flight requests")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, err = input.Write([]byte("000000002\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, input.Close())
+
+	assert.Equal(t, http.ErrServerClosed, <-listened)
+	assert.True(t, numberRepository.Contains(2), "request numbers should be added once listen returns")
+}
+
+func newTestHTTPListener(t *testing.T, slots *clientSlots) *HTTPListener {
+	validator, err := line.NewValidator()
+	assert.NoError(t, err)
+
+	return &HTTPListener{
+		access:        newAccessControl(AccessPolicy{}),
+		errHandle:     errhandler.Noop,
+		lineValidator: validator,
+		numberSet:     repository.NewNumberSet(repository.NewInMemoryRepository()),
+		report:        &report.Report{},
+		slots:         slots,
+		maxBodySize:   httpMaxBodySize,
+		requests:      &inFlightRequests{},
+		stopped:       make(chan struct{}),
+	}
+}
+
+func postNumbers(l *HTTPListener, contentType, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/numbers", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+
+	l.handleNumbers(rec, req)
+
+	return rec
+}
+
+func decodeResult(t *testing.T, rec *httptest.ResponseRecorder) (result HTTPResult) {
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+
+	return
+}
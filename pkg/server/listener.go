@@ -2,30 +2,75 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 
-	"fmt"
 	"net"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
-// Listener listens for connections and sends to output channel
+// Listener listens for connections and sends to output channel the ones admitted by its access policy
 type Listener struct {
 	listener net.Listener
+	access   *accessControl
 	conns    chan<- net.Conn
 }
 
-// NewListener creates new connection listener on given tcp port
-func NewListener(port int, conns chan<- net.Conn) (*Listener, error) {
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+// listen address network prefixes, addresses without prefix are tcp
+var listenNetworks = []string{"tcp", "tcp4", "tcp6", "unix"}
+
+// ListenerOptions optional listener settings
+// * TLS: wraps connections with tls if given
+// * Access: filters accepted connections
+// * UnixSocket: socket file settings for unix addresses
+type ListenerOptions struct {
+	TLS        *tls.Config
+	Access     AccessPolicy
+	UnixSocket UnixSocketConfig
+}
+
+// NewListener creates new connection listener on given address
+// address format is [network:]address, e.g: ":4000", "tcp6:[::1]:4000", "unix:/tmp/numserver.sock"
+func NewListener(address string, options ListenerOptions, conns chan<- net.Conn) (*Listener, error) {
+	network, address := ParseListenAddress(address)
+
+	var listener net.Listener
+	var err error
+	if network == "unix" {
+		listener, err = listenUnix(address, options.UnixSocket)
+	} else {
+		listener, err = net.Listen(network, address)
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot listen on socket tcp/%d", port)
+		return nil, errors.Wrapf(err, "cannot listen on socket %s/%s", network, address)
+	}
+
+	return newListener(listener, options, conns), nil
+}
+
+// newListener creates a connection listener accepting from given one
+func newListener(listener net.Listener, options ListenerOptions, conns chan<- net.Conn) *Listener {
+	if options.TLS != nil {
+		listener = tls.NewListener(listener, options.TLS)
 	}
 
 	return &Listener{
 		listener: listener,
+		access:   newAccessControl(options.Access),
 		conns:    conns,
-	}, nil
+	}
+}
+
+// ParseListenAddress splits a listen address into its network and address
+func ParseListenAddress(listenAddress string) (network, address string) {
+	for _, n := range listenNetworks {
+		if strings.HasPrefix(listenAddress, n+":") {
+			return n, strings.TrimPrefix(listenAddress, n+":")
+		}
+	}
+
+	return "tcp", listenAddress
 }
 
 // Listen listens for new connections
@@ -38,7 +83,19 @@ func (s *Listener) Listen(ctx context.Context) error {
 			return err
 		}
 
-		s.conns <- conn
+		admitted, ok := s.access.admit(conn)
+		if !ok {
+			// rejected without comment, as invalid input
+			_ = conn.Close()
+			continue
+		}
+
+		select {
+		case s.conns <- admitted:
+		case <-ctx.Done():
+			// not handled, as handlers are stopping
+			_ = admitted.Close()
+		}
 	}
 }
 
@@ -48,6 +105,11 @@ func (s *Listener) Stop() {
 	return
 }
 
+// SetAccess changes the access policy applied from now on, already accepted clients are kept
+func (s *Listener) SetAccess(access AccessPolicy) {
+	s.access.setPolicy(access)
+}
+
 func (s *Listener) waitForContextTermination(ctx context.Context) {
 	<-ctx.Done()
 	s.Stop()
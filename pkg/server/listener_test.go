@@ -0,0 +1,26 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListenAddress(t *testing.T) {
+	cases := map[string][2]string{
+		":4000":                    {"tcp", ":4000"},
+		"127.0.0.1:4000":           {"tcp", "127.0.0.1:4000"},
+		"localhost:4000":           {"tcp", "localhost:4000"},
+		"tcp::4000":                {"tcp", ":4000"},
+		"tcp4:0.0.0.0:4000":        {"tcp4", "0.0.0.0:4000"},
+		"tcp6:[::1]:4000":          {"tcp6", "[::1]:4000"},
+		"unix:/tmp/numserver.sock": {"unix", "/tmp/numserver.sock"},
+	}
+
+	for listenAddress, expected := range cases {
+		network, address := ParseListenAddress(listenAddress)
+
+		assert.Equal(t, expected[0], network, listenAddress)
+		assert.Equal(t, expected[1], address, listenAddress)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net"
+
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/repository"
+	"github.com/varas/numserver/pkg/result"
+)
+
+// Option customizes a NumServer created by NewNumServer or NewNumServerWithConfig
+type Option func(*NumServer)
+
+// dependencies injected by options instead of created from config, nil ones are created
+type dependencies struct {
+	repository   repository.NumberRepository
+	sink         result.Sink
+	reportWriter io.Writer
+	listeners    []net.Listener
+}
+
+// WithConcurrentClients sets the concurrent clients limit, shared by tcp and http clients
+func WithConcurrentClients(concurrentClients int) Option {
+	return func(s *NumServer) {
+		s.config.ConcurrentClients = concurrentClients
+	}
+}
+
+// WithRepository stores unique numbers on given repository instead of the configured one, for numbers up to 9 digits.
+// It is not closed on stop, as it is owned by the caller
+func WithRepository(numberRepository repository.NumberRepository) Option {
+	return func(s *NumServer) {
+		s.dependencies.repository = numberRepository
+	}
+}
+
+// WithSink writes unique numbers to given sink instead of the log file, it is closed on stop.
+// Acked clients rely on numbers written being durable
+func WithSink(sink result.Sink) Option {
+	return func(s *NumServer) {
+		s.dependencies.sink = sink
+	}
+}
+
+// WithLogger logs with given structured logger instead of the configured one, errors included unless WithErrHandler is given
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *NumServer) {
+		s.logger = logger
+	}
+}
+
+// WithErrHandler handles errors with given handler instead of logging them
+func WithErrHandler(errHandle errhandler.ErrHandler) Option {
+	return func(s *NumServer) {
+		s.errHandle = errHandle
+	}
+}
+
+// WithReportWriter writes reports to given writer instead of standard output
+func WithReportWriter(w io.Writer) Option {
+	return func(s *NumServer) {
+		s.dependencies.reportWriter = w
+	}
+}
+
+// WithListener accepts tcp clients from given listener, e.g. one already bound or in memory.
+// It replaces the port listener unless listen addresses are configured, and it is closed on stop
+func WithListener(listener net.Listener) Option {
+	return func(s *NumServer) {
+		s.dependencies.listeners = append(s.dependencies.listeners, listener)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+// memorySink keeps numbers written in memory
+type memorySink struct {
+	numbers []uint32
+	sync.Mutex
+}
+
+func (s *memorySink) Write(numbers []uint32) error {
+	s.Lock()
+	s.numbers = append(s.numbers, numbers...)
+	s.Unlock()
+	return nil
+}
+
+func (s *memorySink) Write64(numbers []uint64) error {
+	return fmt.Errorf("unexpected 64-bit numbers")
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func (s *memorySink) written() []uint32 {
+	s.Lock()
+	defer s.Unlock()
+	return append([]uint32{}, s.numbers...)
+}
+
+// syncBuffer buffer safe to write and read concurrently
+type syncBuffer struct {
+	buffer bytes.Buffer
+	sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.String()
+}
+
+func TestNumServer_WithOptions(t *testing.T) {
+	numberRepository := repository.NewInMemoryRepository()
+	numberRepository.AddNumber(1)
+	numberRepository.ExtractTransaction()
+	numberRepository.Commit()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	sink := &memorySink{}
+	reports := &syncBuffer{}
+	port := randPort()
+
+	config := NewConfig(port, testFilePath)
+	config.LogFlushInterval = 10 * time.Millisecond
+	config.ReportFlushInterval = 10 * time.Millisecond
+
+	srv := NewNumServerWithConfig(*config,
+		WithConcurrentClients(2),
+		WithRepository(numberRepository),
+		WithSink(sink),
+		WithReportWriter(reports),
+		WithListener(listener),
+		WithErrHandler(errhandler.Noop),
+	)
+	go srv.Run(context.Background())
+	<-srv.Ready
+	defer close(srv.Stop)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	_, err = client.Write([]byte("000000001\n000000002\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sink.written()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, []uint32{2}, sink.written(), "numbers already on injected repository should be duplicates")
+
+	for !strings.Contains(reports.String(), "Received 1 unique numbers, 1 duplicates") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Contains(t, reports.String(), "Received 1 unique numbers, 1 duplicates")
+
+	assert.Equal(t, 2, srv.config.ConcurrentClients)
+
+	_, err = net.Dial("tcp", fmt.Sprintf(":%d", port))
+	assert.Error(t, err, "injected listener should replace the port one")
+}
+
+func TestNumServer_KeepsWorkingWithoutOptions(t *testing.T) {
+	srv := NewNumServer(randPort(), testFilePath)
+
+	assert.Equal(t, DefaultConcurrentClients, srv.config.ConcurrentClients)
+	assert.Nil(t, srv.dependencies.repository)
+}
+
+func TestNumServer_WithLoggerLogsConnectionErrorsWithFields(t *testing.T) {
+	logs := &syncBuffer{}
+	logger, err := errhandler.NewLogger(logs, errhandler.FormatJSON, "info")
+	assert.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	srv := NewNumServerWithConfig(*NewConfig(randPort(), testFilePath), WithListener(listener), WithLogger(logger))
+	go srv.Run(context.Background())
+	<-srv.Ready
+	defer close(srv.Stop)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	_, err = client.Write([]byte("invalid\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(logs.String(), "\n") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Contains(t, logs.String(), `"level":"ERROR"`)
+	assert.Contains(t, logs.String(), `"conn":1`)
+	assert.Contains(t, logs.String(), `"remote":"`+client.LocalAddr().String()+`"`)
+}
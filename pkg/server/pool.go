@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// handlerPool runs a resizable amount of conn handlers until its context is done
+type handlerPool struct {
+	ctx     context.Context
+	handler *connHandler
+	size    int
+	// each value received stops a handler, once done with its current connection
+	quit    chan struct{}
+	stopped bool
+	wg      sync.WaitGroup
+	sync.Mutex
+}
+
+func newHandlerPool(ctx context.Context, handler *connHandler) *handlerPool {
+	return &handlerPool{
+		ctx:     ctx,
+		handler: handler,
+		quit:    make(chan struct{}),
+	}
+}
+
+// resize starts or stops handlers until there are size, stopped ones finish their current connection first
+// returned channel is closed once all the handlers to stop are done
+func (p *handlerPool) resize(size int) <-chan struct{} {
+	p.Lock()
+	defer p.Unlock()
+
+	done := make(chan struct{})
+	if p.stopped {
+		close(done)
+		return done
+	}
+
+	for ; p.size < size; p.size++ {
+		p.wg.Add(1)
+		go func() {
+			p.handler.run(p.ctx, p.quit)
+			p.wg.Done()
+		}()
+	}
+
+	var stopping sync.WaitGroup
+	for ; p.size > size; p.size-- {
+		stopping.Add(1)
+		go func() {
+			// only received by handlers between connections
+			select {
+			case p.quit <- struct{}{}:
+			case <-p.ctx.Done():
+			}
+			stopping.Done()
+		}()
+	}
+
+	go func() {
+		stopping.Wait()
+		close(done)
+	}()
+
+	return done
+}
+
+// wait waits until all handlers are done, to be called once its context is done
+func (p *handlerPool) wait() {
+	p.Lock()
+	p.stopped = true
+	p.Unlock()
+
+	p.wg.Wait()
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/line"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+// QueryListener serves read-only membership lookups, aimed to listen on a loopback address
+// clients send lines of one or more space separated numbers, server replies a line per query line
+// with a space separated 1 (seen) or 0 (not seen) per number, or E if the line is invalid
+type QueryListener struct {
+	listener      net.Listener
+	errHandle     errhandler.ErrHandler
+	lineValidator *line.Validator
+	numberSet     repository.NumberSet
+	conns         map[net.Conn]struct{}
+	stopped       bool
+	wgConns       sync.WaitGroup
+	sync.Mutex
+}
+
+// NewQueryListener creates a new query listener on given tcp address
+func NewQueryListener(
+	address string,
+	errHandle errhandler.ErrHandler,
+	lineValidator *line.Validator,
+	numberSet repository.NumberSet,
+) (*QueryListener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot listen on socket tcp/%s", address)
+	}
+
+	return &QueryListener{
+		listener:      listener,
+		errHandle:     errHandle,
+		lineValidator: lineValidator,
+		numberSet:     numberSet,
+		conns:         make(map[net.Conn]struct{}),
+	}, nil
+}
+
+// Listen serves queries until stopped, each client on its own goroutine as queries are read-only
+func (s *QueryListener) Listen(ctx context.Context) error {
+	go s.waitForContextTermination(ctx)
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.wgConns.Wait()
+			return err
+		}
+
+		if s.track(conn) {
+			go s.handle(conn)
+		}
+	}
+}
+
+// Stop stops listening and closes open query connections, as no data is lost
+func (s *QueryListener) Stop() {
+	_ = s.listener.Close()
+
+	s.Lock()
+	s.stopped = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.Unlock()
+}
+
+func (s *QueryListener) waitForContextTermination(ctx context.Context) {
+	<-ctx.Done()
+	s.Stop()
+}
+
+// track tracks conn to be closed on stop, returns false if stopped closing it
+func (s *QueryListener) track(conn net.Conn) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	// accepted while stopping
+	if s.stopped {
+		_ = conn.Close()
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+	s.wgConns.Add(1)
+
+	return true
+}
+
+func (s *QueryListener) untrack(conn net.Conn) {
+	s.Lock()
+	delete(s.conns, conn)
+	s.Unlock()
+
+	_ = conn.Close()
+	s.wgConns.Done()
+}
+
+func (s *QueryListener) handle(conn net.Conn) {
+	defer s.untrack(conn)
+
+	reader := line.NewReader(*bufio.NewReader(conn), s.lineValidator)
+	output := bufio.NewWriter(conn)
+
+	for {
+		numbers, err := reader.ReadQueryLine()
+		if err == io.EOF {
+			_ = output.Flush()
+			return
+		}
+
+		if err != nil {
+			_, err = output.WriteString("E\n")
+		} else {
+			_, err = output.Write(s.lookup(numbers))
+		}
+
+		// next read may block, so client should get its replies before
+		if err == nil && reader.Buffered() == 0 {
+			err = output.Flush()
+		}
+
+		if err != nil {
+			s.errHandle(errhandler.WithFields(errors.Wrap(err, "cannot write query reply"), "remote", conn.RemoteAddr().String()))
+			return
+		}
+	}
+}
+
+func (s *QueryListener) lookup(numbers []uint64) []byte {
+	reply := make([]byte, 0, 2*len(numbers))
+
+	for i, n := range numbers {
+		if i > 0 {
+			reply = append(reply, ' ')
+		}
+
+		if s.numberSet.Contains(n) {
+			reply = append(reply, '1')
+		} else {
+			reply = append(reply, '0')
+		}
+	}
+
+	return append(reply, '\n')
+}
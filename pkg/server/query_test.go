@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/line"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+func TestQueryListener_LooksUpWithoutAdding(t *testing.T) {
+	validator, err := line.NewValidator()
+	assert.NoError(t, err)
+
+	repo := repository.NewInMemoryRepository()
+	_ = repo.AddNumber(314159265)
+
+	l, err := NewQueryListener(fmt.Sprintf("127.0.0.1:%d", randPort()), errhandler.Noop, validator, repository.NewNumberSet(repo))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Listen(ctx)
+
+	client, err := net.Dial("tcp", l.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot connect to query listener: %s", err.Error())
+	}
+	defer client.Close()
+
+	reader := bufio.NewReader(client)
+
+	_, err = client.Write([]byte("314159265\n"))
+	assert.NoError(t, err)
+	assertReply(t, reader, "1\n")
+
+	_, err = client.Write([]byte("007007009 314159265\nshort\n007007009\n"))
+	assert.NoError(t, err)
+	assertReply(t, reader, "0 1\n")
+	assertReply(t, reader, "E\n")
+	assertReply(t, reader, "0\n")
+
+	assert.False(t, repo.Contains(7007009), "queries should not add numbers")
+}
+
+func TestQueryListener_DoesNotTrackConnsAcceptedOnStop(t *testing.T) {
+	validator, err := line.NewValidator()
+	assert.NoError(t, err)
+
+	l, err := NewQueryListener("127.0.0.1:0", errhandler.Noop, validator, repository.NewNumberSet(repository.NewInMemoryRepository()))
+	assert.NoError(t, err)
+
+	l.Stop()
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	assert.False(t, l.track(server), "conn accepted on stop should not be handled")
+	assert.Empty(t, l.conns)
+
+	_, err = server.Write([]byte("1\n"))
+	assert.Error(t, err, "conn should be closed")
+}
+
+func assertReply(t *testing.T, reader *bufio.Reader, expected string) {
+	reply, err := reader.ReadString('\n')
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, reply)
+}
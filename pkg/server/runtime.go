@@ -3,7 +3,10 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"reflect"
 
 	"sync"
 
@@ -12,8 +15,6 @@ import (
 	"github.com/varas/numserver/pkg/errhandler"
 	"github.com/varas/numserver/pkg/line"
 	"github.com/varas/numserver/pkg/report"
-	"github.com/varas/numserver/pkg/repository"
-	"github.com/varas/numserver/pkg/result"
 )
 
 // runtime context
@@ -24,19 +25,62 @@ type runtime struct {
 	cancelListener context.CancelFunc
 	cancelHandlers context.CancelFunc
 	cancelRunners  context.CancelFunc
-	wgHandlers     sync.WaitGroup
+	store          *numberStore
+	handlers       *handlerPool
+	wgListeners    sync.WaitGroup
 	wgDaemons      sync.WaitGroup
+	// nil if disabled
+	reportFile io.Closer
+	// services changing settings on reload
+	config       Config
+	reportRunner *report.Runner
+	slots        *clientSlots
+	connHandler  *connHandler
+	listeners    []numberListener
+	// serializes reloads
+	reloading sync.Mutex
 }
 
 // passing config on start enables hot config-reloading
-func (r *runtime) start(ctx context.Context, c config, errHandle errhandler.ErrHandler) (err error) {
+func (r *runtime) start(ctx context.Context, c Config, deps dependencies, errHandle errhandler.ErrHandler) (err error) {
 	r.stopped = make(chan struct{})
 	r.errHandle = errHandle
+	r.config = c
 
-	conns := make(chan net.Conn)
-	listener, err := NewListener(c.port, conns)
+	err = c.Validate()
+	if err != nil {
+		return errors.Wrap(err, "invalid config")
+	}
+
+	lineValidator, err := line.NewFormatValidator(c.LineFormat)
+	if err != nil {
+		return fmt.Errorf("cannot create line validator: %s", err.Error())
+	}
+
+	tlsConfig, err := c.TLS.load()
 	if err != nil {
-		return errors.Wrap(err, "cannot create connection listener")
+		return errors.Wrap(err, "cannot load tls config")
+	}
+
+	listenerOptions := ListenerOptions{
+		TLS:        tlsConfig,
+		Access:     c.Access,
+		UnixSocket: c.UnixSocket,
+	}
+
+	// all listeners feed the same handler pool
+	conns := make(chan net.Conn)
+	var listeners []numberListener
+	for _, address := range c.listenAddresses(len(deps.listeners) == 0) {
+		listener, err := NewListener(address, listenerOptions, conns)
+		if err != nil {
+			stopListeners(listeners)
+			return errors.Wrap(err, "cannot create connection listener")
+		}
+		listeners = append(listeners, listener)
+	}
+	for _, listener := range deps.listeners {
+		listeners = append(listeners, newListener(listener, listenerOptions, conns))
 	}
 
 	// stop runtime in order
@@ -46,25 +90,70 @@ func (r *runtime) start(ctx context.Context, c config, errHandle errhandler.ErrH
 	ctxRunners, r.cancelRunners = context.WithCancel(ctx)
 
 	currentReport := &report.Report{}
-	numberRepository := repository.NewInMemoryRepository()
 
-	reportRunner := report.NewRunner(c.reportFlushInterval, currentReport)
-	resultRunner, err := result.NewRunner(c.logFlushInterval, c.logPath, c.logFlushBatchSize, numberRepository)
+	r.store, err = newNumberStore(c, currentReport, deps, errHandle)
 	if err != nil {
+		stopListeners(listeners)
 		return errors.Wrap(err, "cannot create result runner")
 	}
+	numberSet, resultRunner := r.store.numberSet, r.store.resultRunner
+	resultRunner.SetErrHandler(errHandle)
 
-	// stop bg jobs: listener and runners
-	r.wgDaemons = sync.WaitGroup{}
-	r.wgDaemons.Add(3)
-	go func() {
-		listerErr := listener.Listen(ctxListener)
-		// avoid logging connection closed on teardown
-		if r.isUp.IsSet() {
-			r.errHandle(listerErr)
+	// concurrent clients limit shared by tcp and http
+	slots := newClientSlots(c.ConcurrentClients)
+
+	if c.HTTPAddress != "" {
+		httpListener, err := NewHTTPListener(c.HTTPAddress, tlsConfig, c.Access, errHandle, lineValidator, numberSet, currentReport, slots)
+		if err != nil {
+			stopListeners(listeners)
+			_ = r.store.release()
+			return errors.Wrap(err, "cannot create http listener")
 		}
-		r.wgDaemons.Done()
-	}()
+		listeners = append(listeners, httpListener)
+	}
+
+	if c.QueryAddress != "" {
+		queryListener, err := NewQueryListener(c.QueryAddress, errHandle, lineValidator, numberSet)
+		if err != nil {
+			stopListeners(listeners)
+			_ = r.store.release()
+			return errors.Wrap(err, "cannot create query listener")
+		}
+		listeners = append(listeners, queryListener)
+	}
+
+	if c.UDPAddress != "" {
+		udpListener, err := NewUDPListener(c.UDPAddress, c.Access, lineValidator, numberSet, currentReport)
+		if err != nil {
+			stopListeners(listeners)
+			_ = r.store.release()
+			return errors.Wrap(err, "cannot create udp listener")
+		}
+		listeners = append(listeners, udpListener)
+	}
+
+	reportRunner, err := r.newReportRunner(c, deps, currentReport)
+	if err != nil {
+		stopListeners(listeners)
+		_ = r.store.release()
+		return err
+	}
+
+	// stop bg jobs: listeners and runners
+	r.wgListeners = sync.WaitGroup{}
+	r.wgListeners.Add(len(listeners))
+	for _, listener := range listeners {
+		go func(listener numberListener) {
+			listerErr := listener.Listen(ctxListener)
+			// avoid logging connection closed on teardown
+			if r.isUp.IsSet() {
+				r.errHandle(listerErr)
+			}
+			r.wgListeners.Done()
+		}(listener)
+	}
+	r.wgDaemons = sync.WaitGroup{}
+	r.wgDaemons.Add(2 + len(r.store.daemons))
 	go func() {
 		r.errHandle(reportRunner.Run(ctxRunners))
 		r.wgDaemons.Done()
@@ -73,24 +162,22 @@ func (r *runtime) start(ctx context.Context, c config, errHandle errhandler.ErrH
 		r.errHandle(resultRunner.Run(ctxRunners))
 		r.wgDaemons.Done()
 	}()
+	for _, daemon := range r.store.daemons {
+		go func(run func(context.Context) error) {
+			r.errHandle(run(ctxRunners))
+			r.wgDaemons.Done()
+		}(daemon)
+	}
 
 	terminate := make(chan struct{})
 
-	lineValidator, err := line.NewValidator()
-	if err != nil {
-		return fmt.Errorf("cannot create line validator: %s", err.Error())
-	}
+	connHandler := newConnHandler(errHandle, lineValidator, numberSet, r.store.wal, resultRunner.Commits(), currentReport, c.Termination, slots, conns, terminate)
+	connHandler.setIdleTimeout(c.IdleTimeout)
 
-	connHandler := newConnHandler(errHandle, lineValidator, numberRepository, currentReport, conns, terminate)
+	r.handlers = newHandlerPool(ctxHandlers, connHandler)
+	r.handlers.resize(c.ConcurrentClients)
 
-	r.wgHandlers = sync.WaitGroup{}
-	r.wgHandlers.Add(c.concurrentClients)
-	for w := c.concurrentClients; w > 0; w-- {
-		go func() {
-			connHandler.run(ctxHandlers)
-			r.wgHandlers.Done()
-		}()
-	}
+	r.reportRunner, r.slots, r.connHandler, r.listeners = reportRunner, slots, connHandler, listeners
 
 	go r.waitForClientTermination(terminate)
 	go r.waitForContextTermination(ctx)
@@ -107,18 +194,144 @@ func (r *runtime) stop() {
 	}
 
 	r.cancelListener()
+	// listeners adding numbers themselves, like http and udp ones, return once done
+	r.wgListeners.Wait()
 
 	// stop conn handlers
 	r.cancelHandlers()
-	r.wgHandlers.Wait()
+	r.handlers.wait()
 
 	// stop result & report runners
 	r.cancelRunners()
 	r.wgDaemons.Wait()
 
+	r.store.stop(r.errHandle)
+
+	if r.reportFile != nil {
+		err := r.reportFile.Close()
+		if err != nil {
+			r.errHandle(errors.Wrap(err, "cannot close report file"))
+		}
+	}
+
 	close(r.stopped)
 }
 
+// newReportRunner creates the report runner writing to standard output, or the injected writer,
+// and to the report file if enabled, whose write errors do not stop reports
+func (r *runtime) newReportRunner(c Config, deps dependencies, currentReport *report.Report) (*report.Runner, error) {
+	var output io.Writer = os.Stdout
+	if deps.reportWriter != nil {
+		output = deps.reportWriter
+	}
+
+	r.reportFile = nil
+	if c.ReportFile.Path == "" {
+		return report.NewRunnerWithWriter(c.ReportFlushInterval, currentReport, output), nil
+	}
+
+	reportFile, err := report.NewRotatingFile(c.ReportFile.Path, int64(c.ReportFile.MaxSize), c.ReportFile.Backups)
+	if err != nil {
+		return nil, err
+	}
+	r.reportFile = reportFile
+
+	return report.NewRunnerWithWriters(c.ReportFlushInterval, currentReport, output, r.errHandle, reportFile), nil
+}
+
+// reload applies the reloadable settings of given config without dropping connections nor numbers,
+// returning an error if any other setting changed, as those are only applied on start
+func (r *runtime) reload(c Config) error {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+
+	if r.isUp == nil || !r.isUp.IsSet() {
+		return errors.New("server is not running")
+	}
+
+	err := c.Validate()
+	if err != nil {
+		return errors.Wrap(err, "invalid config")
+	}
+
+	reloaded := r.config.withReloaded(c)
+
+	r.reportRunner.SetInterval(reloaded.ReportFlushInterval)
+	r.store.resultRunner.SetInterval(reloaded.LogFlushInterval)
+	r.store.resultRunner.SetFlushBatchSize(reloaded.LogFlushBatchSize)
+
+	// clients over a smaller limit finish in background
+	r.resize(reloaded.ConcurrentClients)
+	r.connHandler.setIdleTimeout(reloaded.IdleTimeout)
+
+	for _, listener := range r.listeners {
+		if l, ok := listener.(accessFilter); ok {
+			l.SetAccess(reloaded.Access)
+		}
+	}
+
+	r.config = reloaded
+
+	if !reflect.DeepEqual(reloaded, c) {
+		return errors.New("only intervals, batch size, concurrent clients, idle timeout and access are reloaded, restart to apply other changes")
+	}
+
+	return nil
+}
+
+// setConcurrency changes the concurrent clients limit, waiting on shrink until clients over it finish
+// returns the context error if done before, although they are not interrupted
+func (r *runtime) setConcurrency(ctx context.Context, concurrentClients int) error {
+	if concurrentClients <= 0 {
+		return errors.New("concurrent clients must be positive")
+	}
+
+	r.reloading.Lock()
+	if r.isUp == nil || !r.isUp.IsSet() {
+		r.reloading.Unlock()
+		return errors.New("server is not running")
+	}
+	handlersStopped := r.resize(concurrentClients)
+	r.config.ConcurrentClients = concurrentClients
+	r.reloading.Unlock()
+
+	select {
+	case <-handlersStopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// http requests share the slots
+	if !r.slots.waitFits(ctx) {
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// resize resizes client slots and handlers, returned channel is closed once handlers over the limit are done
+func (r *runtime) resize(concurrentClients int) <-chan struct{} {
+	r.slots.resize(concurrentClients)
+	return r.handlers.resize(concurrentClients)
+}
+
+// accessFilter listener filtering clients by an access policy changeable while listening
+type accessFilter interface {
+	SetAccess(access AccessPolicy)
+}
+
+// numberListener ingests numbers until its context is done
+type numberListener interface {
+	Listen(ctx context.Context) error
+	Stop()
+}
+
+func stopListeners(listeners []numberListener) {
+	for _, listener := range listeners {
+		listener.Stop()
+	}
+}
+
 func (r *runtime) waitForClientTermination(termination <-chan struct{}) {
 	<-termination
 	r.stop()
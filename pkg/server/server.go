@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"log/slog"
+	"os"
 
 	"github.com/pkg/errors"
 	"github.com/varas/numserver/pkg/errhandler"
@@ -10,24 +12,45 @@ import (
 // NumServer tcp server that store unique numbers writen
 // It works as a bg daemon, so its API is based on channels to trigger graceful stop and wait for state completions
 type NumServer struct {
-	config    config
-	runtime   *runtime
-	errHandle errhandler.ErrHandler
-	Ready     chan struct{} // enables to wait until ready
-	Stop      chan struct{} // enables to gracefully stop the server
-	Stopped   chan struct{} // enables to wait until stopped
+	config       Config
+	dependencies dependencies
+	runtime      *runtime
+	logger       *slog.Logger
+	errHandle    errhandler.ErrHandler
+	Ready        chan struct{} // enables to wait until ready
+	Stop         chan struct{} // enables to gracefully stop the server
+	Stopped      chan struct{} // enables to wait until stopped
 }
 
-// NewNumServer generates a new num-server
-func NewNumServer(port int, logPath string) *NumServer {
-	errHandle := errhandler.Logger("[error] ")
+// NewNumServer generates a new num-server with default config customized by given options
+func NewNumServer(port int, logPath string, options ...Option) *NumServer {
+	return NewNumServerWithConfig(*NewConfig(port, logPath), options...)
+}
+
+// NewNumServerWithConfig generates a new num-server with given config customized by given options
+func NewNumServerWithConfig(config Config, options ...Option) *NumServer {
+	logger, err := config.Logging.newLogger(os.Stderr)
+	if err != nil {
+		// invalid config is reported on start
+		logger, _ = LoggingConfig{Level: DefaultLoggingLevel, Format: DefaultLoggingFormat}.newLogger(os.Stderr)
+	}
 
-	return &NumServer{
-		config:    *newConfig(port, logPath),
-		runtime:   &runtime{}, // stateless runtime to enable restart
-		errHandle: errHandle,
-		Ready:     make(chan struct{}),
+	s := &NumServer{
+		config:  config,
+		runtime: &runtime{}, // stateless runtime to enable restart
+		logger:  logger,
+		Ready:   make(chan struct{}),
 	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	if s.errHandle == nil {
+		s.errHandle = errhandler.Structured(s.logger)
+	}
+
+	return s
 }
 
 // Run bootstraps the runtime so resilience could be added via recover, and runs the app
@@ -36,7 +59,7 @@ func (s *NumServer) Run(ctx context.Context) {
 	s.Stop = make(chan struct{})
 	s.Stopped = make(chan struct{})
 
-	err := s.runtime.start(ctx, s.config, s.errHandle)
+	err := s.runtime.start(ctx, s.config, s.dependencies, s.errHandle)
 	if err != nil {
 		s.errHandle(errors.Wrap(err, "error on start"))
 		return
@@ -51,6 +74,19 @@ func (s *NumServer) Run(ctx context.Context) {
 	s.stop()
 }
 
+// Reload applies to the running server the report and log flush intervals, log flush batch size,
+// concurrent clients, idle timeout and access policy of given config, keeping connections and numbers.
+// Other settings need a restart, an error is returned if any of them changed.
+func (s *NumServer) Reload(config Config) error {
+	return s.runtime.reload(config)
+}
+
+// SetConcurrency changes the concurrent clients limit of the running server, shared by tcp and http clients.
+// On shrink clients over the limit are not interrupted, it waits until they finish or context is done.
+func (s *NumServer) SetConcurrency(ctx context.Context, concurrentClients int) error {
+	return s.runtime.setConcurrency(ctx, concurrentClients)
+}
+
 func (s *NumServer) stop() {
 	if s.runtime.isUp.IsSet() {
 		s.runtime.stop()
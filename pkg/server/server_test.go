@@ -3,8 +3,13 @@ package server
 import (
 	"context"
 	"fmt"
-	"math/rand"
+	"io"
+	"io/ioutil"
 	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -117,11 +122,630 @@ func TestNumServer_SupportsConcurrentClientWrites(t *testing.T) {
 	wg.Wait()
 }
 
+func TestNumServer_HandlesErrorsOnUnauthorizedTermination(t *testing.T) {
+	wg := sync.WaitGroup{}
+	wg.Add(1) // 1 rejected termination
+
+	spyHandler, handledAmount := countHandler(&wg)
+
+	port := runServerWithConfig(spyHandler, func(c *Config) {
+		c.Termination.Token = "s3cret"
+	})
+
+	client, err := net.Dial("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	defer client.Close()
+
+	_, err = client.Write([]byte("terminate\n" + validOneLineInput))
+	assert.NoError(t, err)
+
+	wg.Wait()
+
+	assert.Equal(t, int32(1), *handledAmount, "unauthorized termination should cause an error being handled")
+}
+
+func TestNumServer_ListensOnMultipleAddresses(t *testing.T) {
+	dir := t.TempDir()
+
+	tcpAddress := fmt.Sprintf("127.0.0.1:%d", randPort())
+	socketPath := filepath.Join(dir, "numserver.sock")
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	runServerWithConfig(errhandler.Noop, func(c *Config) {
+		c.ListenAddresses = []string{tcpAddress, "unix:" + socketPath}
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+	})
+
+	for network, address := range map[string]string{"tcp": tcpAddress, "unix": socketPath} {
+		client, err := net.Dial(network, address)
+		if err != nil {
+			t.Fatalf("cannot connect to server on %s: %s", network, err.Error())
+		}
+
+		_, err = client.Write([]byte(fmt.Sprintf("%09d\n", len(network))))
+		assert.NoError(t, err)
+		assert.NoError(t, client.Close())
+	}
+
+	assertLogEventuallyContains(t, logPath, "3\n", "4\n")
+}
+
+func TestNumServer_FailsToStartOnInvalidLineFormat(t *testing.T) {
+	config := NewConfig(randPort(), testFilePath)
+	config.LineFormat.Newline = "cr"
+
+	err := (&runtime{}).start(context.Background(), *config, dependencies{}, errhandler.Noop)
+
+	assert.Error(t, err)
+}
+
+func TestNumServer_ReleasesResourcesOnFailedStart(t *testing.T) {
+	dir := t.TempDir()
+
+	// a segment left by a previous run, kept open once recovered
+	walDir := filepath.Join(dir, "wal")
+	assert.NoError(t, os.MkdirAll(walDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(walDir, "000001-000000000001.wal"), []byte("7\n"), 0644))
+
+	// http listener fails to start
+	taken, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	defer taken.Close()
+
+	config := NewConfig(randPort(), filepath.Join(dir, DefaultLogFile))
+	config.WALDir = walDir
+	config.HTTPAddress = taken.Addr().String()
+
+	sink := &closingSink{}
+	err = (&runtime{}).start(context.Background(), *config, dependencies{sink: sink}, errhandler.Noop)
+
+	assert.Error(t, err)
+	assert.True(t, sink.closed, "result sink should be closed")
+	assert.Empty(t, openFilesIn(t, walDir), "wal segments should be closed")
+
+	segments, err := filepath.Glob(filepath.Join(walDir, "*.wal"))
+	assert.NoError(t, err)
+	if assert.Len(t, segments, 1) {
+		content, err := ioutil.ReadFile(segments[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "7\n", string(content), "recovered numbers should be kept for next start")
+	}
+}
+
+// closingSink memory sink recording whether it was closed
+type closingSink struct {
+	memorySink
+	closed bool
+}
+
+func (s *closingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// openFilesIn returns the files under dir opened by this process
+func openFilesIn(t *testing.T, dir string) (files []string) {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("cannot list open files")
+	}
+
+	for _, fd := range fds {
+		path, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err == nil && strings.HasPrefix(path, dir) {
+			files = append(files, path)
+		}
+	}
+
+	return
+}
+
+func TestNumServer_Supports64BitNumbers(t *testing.T) {
+	dir := t.TempDir()
+
+	port := randPort()
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	runServerWithConfig(errhandler.Noop, func(c *Config) {
+		c.Port = port
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+		c.LineFormat.Digits = 19
+	})
+
+	client, err := net.Dial("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+
+	_, err = client.Write([]byte("9999999999999999999\n0000000004294967296\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	assertLogEventuallyContains(t, logPath, "9999999999999999999\n", "4294967296\n")
+}
+
+func TestNumServer_DedupesWithBloomRepository(t *testing.T) {
+	dir := t.TempDir()
+
+	port := randPort()
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	runServerWithConfig(errhandler.Noop, func(c *Config) {
+		c.Port = port
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+		c.Repository.Type = RepositoryBloom
+		c.Repository.BloomCapacity = 1000
+	})
+
+	client, err := net.Dial("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+
+	_, err = client.Write([]byte("000000001\n000000002\n000000001\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	assertLogEventuallyContains(t, logPath, "1\n", "2\n")
+
+	content, err := ioutil.ReadFile(logPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "1\n"), "duplicate should not be logged")
+	assert.FileExists(t, logPath+".bitmap")
+}
+
+func TestNumServer_LogsNumbersAgainAfterTTL(t *testing.T) {
+	dir := t.TempDir()
+
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	port := runServerWithConfig(errhandler.Noop, func(c *Config) {
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+		c.Repository.Type = RepositoryTTL
+		c.Repository.TTL = 100 * time.Millisecond
+		c.Repository.TTLBuckets = 2
+	})
+
+	send := func(input string) {
+		client, err := net.Dial("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			t.Fatalf("cannot connect to server: %s", err.Error())
+		}
+
+		_, err = client.Write([]byte(input))
+		assert.NoError(t, err)
+		assert.NoError(t, client.Close())
+	}
+
+	send("000000001\n000000001\n")
+	assertLogEventuallyContains(t, logPath, "1\n")
+
+	time.Sleep(200 * time.Millisecond)
+
+	send("000000001\n")
+	assertLogEventuallyContains(t, logPath, "1\n1\n")
+}
+
+func TestNumServer_LogsNumbersAgainOnNewEpoch(t *testing.T) {
+	dir := t.TempDir()
+
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	port := runServerWithConfig(errhandler.Noop, func(c *Config) {
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+		c.Epochs.Interval = time.Second
+	})
+
+	send := func(input string) {
+		client, err := net.Dial("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			t.Fatalf("cannot connect to server: %s", err.Error())
+		}
+
+		_, err = client.Write([]byte(input))
+		assert.NoError(t, err)
+		assert.NoError(t, client.Close())
+	}
+
+	send("000000001\n000000001\n")
+	assertLogEventuallyContains(t, logPath, "1\n")
+
+	// waits for the epoch end finalizing the log
+	deadline := time.Now().Add(3 * time.Second)
+	finalized, _ := filepath.Glob(logPath + ".*")
+	for len(finalized) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("log was not finalized on epoch end")
+		}
+		time.Sleep(10 * time.Millisecond)
+		finalized, _ = filepath.Glob(logPath + ".*")
+	}
+	assertLogEventuallyContains(t, finalized[0], "1\n")
+
+	send("000000001\n")
+	assertLogEventuallyContains(t, logPath, "1\n")
+}
+
+func TestNumServer_FailsToStartEpochsOnUnresettableRepository(t *testing.T) {
+	dir := t.TempDir()
+
+	config := NewConfig(randPort(), filepath.Join(dir, DefaultLogFile))
+	config.Repository.Type = RepositoryDisk
+	config.Epochs.Interval = time.Hour
+
+	err := (&runtime{}).start(context.Background(), *config, dependencies{}, errhandler.Noop)
+
+	assert.Error(t, err)
+}
+
+func TestNumServer_FailsToStartBloomRepositoryWith64BitNumbers(t *testing.T) {
+	config := NewConfig(randPort(), testFilePath)
+	config.LineFormat.Digits = 19
+	config.Repository.Type = RepositoryBloom
+
+	err := (&runtime{}).start(context.Background(), *config, dependencies{}, errhandler.Noop)
+
+	assert.Error(t, err)
+}
+
+func TestNumServer_KeepsNumbersOnRestart(t *testing.T) {
+	persistences := map[string]func(c *Config){
+		RepositoryDisk: func(c *Config) { c.Repository.Type = RepositoryDisk },
+		RepositoryMmap: func(c *Config) { c.Repository.Type = RepositoryMmap },
+		"snapshot":     func(c *Config) { c.Snapshot.Path = c.LogPath + ".snapshot" },
+	}
+
+	for name, persist := range persistences {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			config := NewConfig(randPort(), filepath.Join(dir, DefaultLogFile))
+			config.LogFlushInterval = 10 * time.Millisecond
+			persist(config)
+
+			for _, input := range []string{"000000001\n000000002\n", "000000002\n000000003\n"} {
+				srv := NewNumServerWithConfig(*config)
+				srv.errHandle = errhandler.Noop
+
+				go srv.Run(context.Background())
+				<-srv.Ready
+
+				client, err := net.Dial("tcp", fmt.Sprintf(":%d", config.Port))
+				if err != nil {
+					t.Fatalf("cannot connect to server: %s", err.Error())
+				}
+
+				_, err = client.Write([]byte(input))
+				assert.NoError(t, err)
+				assert.NoError(t, client.Close())
+
+				assertLogEventuallyContains(t, config.LogPath, strings.TrimLeft(input[10:], "0"))
+
+				close(srv.Stop)
+				<-srv.Stopped
+			}
+
+			content, err := ioutil.ReadFile(config.LogPath)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, strings.Count(string(content), "\n"), "numbers of previous run should be kept and not logged again")
+		})
+	}
+}
+
+// crashedServerEnv set on the subprocess running the server to be killed, holding its temp dir and port
+const crashedServerEnv = "NUMSERVER_TEST_CRASHED_SERVER"
+
+func TestNumServer_RecoversNumbersFromWALAfterCrash(t *testing.T) {
+	if env := os.Getenv(crashedServerEnv); env != "" {
+		runCrashedServer(env)
+		return
+	}
+
+	dir := t.TempDir()
+
+	logPath := filepath.Join(dir, DefaultLogFile)
+	walDir := filepath.Join(dir, "wal")
+	crashedPort := randPort()
+
+	// killed mid-interval on a subprocess: it never flushes nor stops, its numbers are only on the wal
+	crashed := exec.Command(os.Args[0], "-test.run=^TestNumServer_RecoversNumbersFromWALAfterCrash$")
+	crashed.Env = append(os.Environ(), fmt.Sprintf("%s=%s:%d", crashedServerEnv, dir, crashedPort))
+	if err := crashed.Start(); err != nil {
+		t.Fatalf("cannot start crashed server: %s", err.Error())
+	}
+	defer crashed.Process.Kill()
+
+	client := dialEventually(t, crashedPort)
+
+	_, err := client.Write([]byte("000000001\n000000002\n000000001\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	assertWALEventuallyContains(t, walDir, "1\n", "2\n")
+
+	assert.NoError(t, crashed.Process.Kill())
+	_ = crashed.Wait()
+
+	content, err := ioutil.ReadFile(logPath)
+	assert.NoError(t, err)
+	assert.Empty(t, content, "numbers should not be logged before crash")
+
+	config := NewConfig(randPort(), logPath)
+	config.LogFlushInterval = 10 * time.Millisecond
+	config.WALDir = walDir
+
+	srv := NewNumServerWithConfig(*config)
+	srv.errHandle = errhandler.Noop
+
+	go srv.Run(context.Background())
+	<-srv.Ready
+
+	assertLogEventuallyContains(t, logPath, "1\n", "2\n")
+
+	close(srv.Stop)
+	<-srv.Stopped
+
+	content, err = ioutil.ReadFile(logPath)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "2"}, strings.Fields(string(content)), "wal numbers should be logged once")
+}
+
+// runCrashedServer runs the server of a crash test on given "dir:port" until the subprocess is killed
+func runCrashedServer(env string) {
+	separator := strings.LastIndex(env, ":")
+	dir := env[:separator]
+	port, err := strconv.Atoi(env[separator+1:])
+	if err != nil {
+		os.Exit(1)
+	}
+
+	config := NewConfig(port, filepath.Join(dir, DefaultLogFile))
+	config.LogFlushInterval = time.Hour
+	config.WALDir = filepath.Join(dir, "wal")
+
+	srv := NewNumServerWithConfig(*config)
+	srv.errHandle = errhandler.Noop
+
+	go srv.Run(context.Background())
+	<-srv.Ready
+
+	// exits if never killed, e.g. on a failed test
+	time.Sleep(time.Minute)
+	os.Exit(1)
+}
+
+// dialEventually connects to a server started on another process
+func dialEventually(t *testing.T, port int) net.Conn {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		client, err := net.Dial("tcp", fmt.Sprintf(":%d", port))
+		if err == nil {
+			return client
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("cannot connect to server: %s", err.Error())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func assertWALEventuallyContains(t *testing.T, walDir string, lines ...string) {
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		var content []byte
+		files, _ := ioutil.ReadDir(walDir)
+		for _, f := range files {
+			segment, err := ioutil.ReadFile(filepath.Join(walDir, f.Name()))
+			assert.NoError(t, err)
+			content = append(content, segment...)
+		}
+
+		missing := 0
+		for _, l := range lines {
+			if !strings.Contains(string(content), l) {
+				missing++
+			}
+		}
+
+		if missing == 0 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("wal %s does not contain %q: %q", walDir, lines, content)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNumServer_ReloadsConfigKeepingConnections(t *testing.T) {
+	dir := t.TempDir()
+
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	srv, config := runNumServer(func(c *Config) {
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+		c.ConcurrentClients = 1
+	})
+
+	// takes the only handler
+	busy, err := net.Dial("tcp", fmt.Sprintf(":%d", config.Port))
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	_, err = busy.Write([]byte("000000001\n"))
+	assert.NoError(t, err)
+	assertLogEventuallyContains(t, logPath, "1\n")
+
+	reloaded := config
+	reloaded.ConcurrentClients = 2
+	reloaded.LogFlushBatchSize = 1
+	assert.NoError(t, srv.Reload(reloaded))
+
+	client, err := net.Dial("tcp", fmt.Sprintf(":%d", config.Port))
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	_, err = client.Write([]byte("000000002\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+	assertLogEventuallyContains(t, logPath, "2\n")
+
+	_, err = busy.Write([]byte("000000003\n000000001\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, busy.Close())
+	assertLogEventuallyContains(t, logPath, "3\n")
+
+	content, err := ioutil.ReadFile(logPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "1\n"), "numbers should be kept on reload")
+}
+
+func TestNumServer_ReloadClosesIdleConnections(t *testing.T) {
+	srv, config := runNumServer(func(*Config) {})
+
+	reloaded := config
+	reloaded.IdleTimeout = 50 * time.Millisecond
+	assert.NoError(t, srv.Reload(reloaded))
+
+	client, err := net.Dial("tcp", fmt.Sprintf(":%d", config.Port))
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	defer client.Close()
+
+	assert.NoError(t, client.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err = client.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "idle connection should be closed by server")
+}
+
+func TestNumServer_ReloadFailsOnSettingsNeedingRestart(t *testing.T) {
+	srv, config := runNumServer(func(*Config) {})
+
+	reloaded := config
+	reloaded.Port = randPort()
+	reloaded.ReportFlushInterval = time.Minute
+
+	assert.Error(t, srv.Reload(reloaded))
+
+	reloaded.ReportFlushInterval = 0
+	assert.Error(t, srv.Reload(reloaded), "invalid settings should not be reloaded")
+}
+
+func TestNumServer_SetConcurrencyWaitsForInFlightConnectionsOnShrink(t *testing.T) {
+	dir := t.TempDir()
+
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	srv, config := runNumServer(func(c *Config) {
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+		c.ConcurrentClients = 1
+	})
+
+	assert.NoError(t, srv.SetConcurrency(context.Background(), 2))
+
+	// both handled at once
+	var clients []net.Conn
+	for i := 1; i <= 2; i++ {
+		client, err := net.Dial("tcp", fmt.Sprintf(":%d", config.Port))
+		if err != nil {
+			t.Fatalf("cannot connect to server: %s", err.Error())
+		}
+		_, err = client.Write([]byte(fmt.Sprintf("%09d\n", i)))
+		assert.NoError(t, err)
+		clients = append(clients, client)
+	}
+	assertLogEventuallyContains(t, logPath, "1\n", "2\n")
+
+	shrunk := make(chan error)
+	go func() {
+		shrunk <- srv.SetConcurrency(context.Background(), 1)
+	}()
+
+	select {
+	case <-shrunk:
+		t.Fatal("shrink should wait for in-flight connections")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// in-flight connections are not interrupted
+	_, err := clients[1].Write([]byte("000000003\n"))
+	assert.NoError(t, err)
+	assertLogEventuallyContains(t, logPath, "3\n")
+
+	assert.NoError(t, clients[0].Close())
+
+	select {
+	case err := <-shrunk:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("shrink should be done once a connection finished")
+	}
+
+	assert.NoError(t, clients[1].Close())
+}
+
+func TestNumServer_SetConcurrencyFailsOnInvalidLimit(t *testing.T) {
+	srv, _ := runNumServer(func(*Config) {})
+
+	assert.Error(t, srv.SetConcurrency(context.Background(), 0))
+}
+
+func TestNumServer_WritesReportsToFile(t *testing.T) {
+	dir := t.TempDir()
+
+	reportPath := filepath.Join(dir, "reports.log")
+
+	runServerWithConfig(errhandler.Noop, func(c *Config) {
+		c.ReportFlushInterval = 10 * time.Millisecond
+		c.ReportFile.Path = reportPath
+	})
+
+	assertLogEventuallyContains(t, reportPath, "Received 0 unique numbers, 0 duplicates. Unique total: 0\n")
+}
+
+// runNumServer runs a server returning it with its config
+func runNumServer(configure func(*Config)) (*NumServer, Config) {
+	config := NewConfig(randPort(), testFilePath)
+	configure(config)
+
+	srv := NewNumServerWithConfig(*config)
+	srv.errHandle = errhandler.Noop
+
+	go srv.Run(context.Background())
+
+	// wait for runtime start
+	<-srv.Ready
+
+	return srv, *config
+}
+
 func runServer(errHandler errhandler.ErrHandler) (port int) {
+	return runServerWithConfig(errHandler, func(*Config) {})
+}
+
+func runServerWithConfig(errHandler errhandler.ErrHandler, configure func(*Config)) (port int) {
 	port = randPort()
 
 	// should create file truncating if exists
-	srv := NewNumServer(port, testFilePath)
+	config := NewConfig(port, testFilePath)
+	configure(config)
+
+	srv := NewNumServerWithConfig(*config)
 	srv.errHandle = errHandler
 
 	go srv.Run(context.Background())
@@ -140,12 +764,16 @@ func runServerAndClient(errHandler errhandler.ErrHandler) (client net.Conn, err
 	return
 }
 
-// range: 49152 to 65535
-// https://www.iana.org/assignments/service-names-port-numbers/service-names-port-numbers.xhtml
+// randPort returns a port free at the moment, picked by the os among its ephemeral ones,
+// as servers of previous tests may be still listening
 func randPort() int {
-	rand.Seed(int64(time.Now().Nanosecond()))
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		panic(fmt.Sprintf("cannot find a free port: %s", err.Error()))
+	}
+	defer listener.Close()
 
-	return 49152 + rand.Intn(65535-49152)
+	return listener.Addr().(*net.TCPAddr).Port
 }
 
 func countHandler(wg *sync.WaitGroup) (errhandler.ErrHandler, *int32) {
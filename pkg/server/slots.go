@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// clientSlots limits concurrent clients across all ingestion paths, resizable at runtime
+type clientSlots struct {
+	size int
+	used int
+	// closed and replaced each time a slot may have become free
+	freed chan struct{}
+	sync.Mutex
+}
+
+func newClientSlots(size int) *clientSlots {
+	return &clientSlots{
+		size:  size,
+		freed: make(chan struct{}),
+	}
+}
+
+// acquire waits for a free slot, returns false if context is done before
+func (s *clientSlots) acquire(ctx context.Context) bool {
+	for {
+		s.Lock()
+		if s.used < s.size {
+			s.used++
+			s.Unlock()
+			return true
+		}
+		freed := s.freed
+		s.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (s *clientSlots) release() {
+	s.Lock()
+	s.used--
+	s.notifyFreed()
+	s.Unlock()
+}
+
+// resize changes the limit, on shrink clients over it keep their slots until released
+func (s *clientSlots) resize(size int) {
+	s.Lock()
+	s.size = size
+	s.notifyFreed()
+	s.Unlock()
+}
+
+// waitFits waits until used slots fit on the limit, returns false if context is done before
+func (s *clientSlots) waitFits(ctx context.Context) bool {
+	for {
+		s.Lock()
+		if s.used <= s.size {
+			s.Unlock()
+			return true
+		}
+		freed := s.freed
+		s.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// notifyFreed wakes up waiting clients, must be called locked
+func (s *clientSlots) notifyFreed() {
+	close(s.freed)
+	s.freed = make(chan struct{})
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSlots_GrowWakesUpWaitingClients(t *testing.T) {
+	slots := newClientSlots(1)
+	assert.True(t, slots.acquire(context.Background()))
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- slots.acquire(context.Background())
+	}()
+
+	slots.resize(2)
+
+	select {
+	case ok := <-acquired:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("waiting client should acquire a slot once grown")
+	}
+}
+
+func TestClientSlots_ShrinkKeepsAcquiredSlots(t *testing.T) {
+	slots := newClientSlots(2)
+	assert.True(t, slots.acquire(context.Background()))
+	assert.True(t, slots.acquire(context.Background()))
+
+	slots.resize(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.False(t, slots.waitFits(ctx), "acquired slots should be kept over the limit")
+	assert.False(t, slots.acquire(ctx))
+
+	slots.release()
+
+	assert.True(t, slots.waitFits(context.Background()))
+}
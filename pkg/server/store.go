@@ -0,0 +1,318 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/report"
+	"github.com/varas/numserver/pkg/repository"
+	"github.com/varas/numserver/pkg/result"
+	"github.com/varas/numserver/pkg/snapshot"
+	"github.com/varas/numserver/pkg/wal"
+)
+
+// numberStore repository wiring: the number set fed by ingestion, the runner logging its unique numbers
+// and the daemons keeping its state, like snapshots and write-ahead log
+type numberStore struct {
+	numberSet    repository.NumberSet
+	resultRunner *result.Runner
+	// closed by the result runner once done
+	sink    result.Sink
+	daemons []func(context.Context) error
+	// 32-bit repository, nil for 64-bit numbers
+	repository   repository.NumberRepository
+	snapshotPath string
+	// nil if disabled
+	wal *wal.Log
+	// releases repository resources
+	close func() error
+}
+
+// newNumberStore creates the configured repository fitting line format numbers, the 32-bit one if possible
+// as it is faster, restoring its snapshot and write-ahead log if any
+// injected repository and sink are used instead of the configured ones, daemon errors not stopping them are handled
+func newNumberStore(c Config, currentReport *report.Report, deps dependencies, errHandle errhandler.ErrHandler) (*numberStore, error) {
+	newSink := func() (result.Sink, error) {
+		if deps.sink != nil {
+			return deps.sink, nil
+		}
+
+		// numbers kept from previous runs are already logged
+		newWriter := result.NewWriter
+		if c.keepsNumbers() {
+			newWriter = result.NewAppendWriter
+		}
+
+		writer, err := newWriter(c.LogPath, c.LogFlushBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create result writer: %s", err.Error())
+		}
+
+		return writer, nil
+	}
+
+	var store *numberStore
+	var err error
+	if c.LineFormat.Digits > maxDigits32 {
+		store, err = newNumberStore64(c, deps.repository, newSink)
+	} else {
+		store, err = newNumberStore32(c, currentReport, deps.repository, newSink, errHandle)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.WALDir != "" {
+		var recovered []uint64
+		store.wal, recovered, err = wal.Open(c.WALDir, store.resultRunner.Commits())
+		if err != nil {
+			_ = store.release()
+			return nil, errors.Wrap(err, "cannot open wal")
+		}
+
+		for _, n := range recovered {
+			_ = store.numberSet.Add(n)
+		}
+
+		store.daemons = append(store.daemons, store.wal.Run)
+	}
+
+	return store, nil
+}
+
+func newNumberStore64(c Config, injected repository.NumberRepository, newSink func() (result.Sink, error)) (*numberStore, error) {
+	if injected != nil {
+		return nil, fmt.Errorf("injected repositories only support up to %d digits", maxDigits32)
+	}
+
+	if c.Repository.Type != RepositoryMemory && c.Repository.Type != "" {
+		return nil, fmt.Errorf("%s repository only supports up to %d digits", c.Repository.Type, maxDigits32)
+	}
+
+	if c.Snapshot.Path != "" {
+		return nil, fmt.Errorf("snapshots only support up to %d digits", maxDigits32)
+	}
+
+	if c.Epochs.Interval > 0 {
+		return nil, fmt.Errorf("epochs only support up to %d digits", maxDigits32)
+	}
+
+	sink, err := newSink()
+	if err != nil {
+		return nil, err
+	}
+
+	numberRepository := repository.NewInMemoryRepository64()
+
+	return &numberStore{
+		numberSet:    repository.NewNumberSet64(numberRepository),
+		resultRunner: result.NewRunner64WithSink(c.LogFlushInterval, sink, numberRepository),
+		sink:         sink,
+		close:        func() error { return nil },
+	}, nil
+}
+
+func newNumberStore32(
+	c Config,
+	currentReport *report.Report,
+	injected repository.NumberRepository,
+	newSink func() (result.Sink, error),
+	errHandle errhandler.ErrHandler,
+) (*numberStore, error) {
+	// injected repository is owned by the caller
+	numberRepository, closeRepository := injected, func() error { return nil }
+	if injected == nil {
+		var err error
+		numberRepository, closeRepository, err = newRepository(c, currentReport)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create %s repository", c.Repository.Type)
+		}
+	}
+
+	var daemons []func(context.Context) error
+	ttlRepository, isTTL := numberRepository.(*repository.TTLRepository)
+	if isTTL {
+		daemons = append(daemons, func(ctx context.Context) error {
+			return runExpiration(ctx, c.ReportFlushInterval, ttlRepository, currentReport)
+		})
+	}
+
+	if c.Snapshot.Path != "" {
+		_, err := snapshot.Load(c.Snapshot.Path, numberRepository)
+		if err != nil {
+			_ = closeRepository()
+			return nil, err
+		}
+	}
+
+	sink, err := newSink()
+	if err != nil {
+		_ = closeRepository()
+		return nil, err
+	}
+
+	resultRunner, err := newResultRunner(c, currentReport, sink, numberRepository)
+	if err != nil {
+		_ = sink.Close()
+		_ = closeRepository()
+		return nil, err
+	}
+
+	store := &numberStore{
+		numberSet:    repository.NewNumberSet(numberRepository),
+		resultRunner: resultRunner,
+		sink:         sink,
+		repository:   numberRepository,
+		snapshotPath: c.Snapshot.Path,
+		daemons:      daemons,
+		close:        closeRepository,
+	}
+
+	if c.Snapshot.Path != "" && c.Snapshot.Interval > 0 {
+		snapshotRunner := snapshot.NewRunner(c.Snapshot.Interval, c.Snapshot.Path, numberRepository, errHandle)
+		store.daemons = append(store.daemons, snapshotRunner.Run)
+	}
+
+	return store, nil
+}
+
+// newResultRunner creates the runner logging repository numbers, on epochs if enabled, reporting the current one
+func newResultRunner(c Config, currentReport *report.Report, sink result.Sink, numberRepository repository.NumberRepository) (*result.Runner, error) {
+	if c.Epochs.Interval <= 0 {
+		return result.NewRunnerWithSink(c.LogFlushInterval, sink, numberRepository), nil
+	}
+
+	// finalized epochs are log files
+	writer, ok := sink.(*result.Writer)
+	if !ok {
+		return nil, errors.New("epochs are only supported writing to the log file")
+	}
+
+	resettable, ok := numberRepository.(repository.ResettableRepository)
+	if !ok {
+		return nil, fmt.Errorf("%s repository does not support epochs", c.Repository.Type)
+	}
+
+	// restored numbers could be from a previous epoch
+	if c.Snapshot.Path != "" {
+		return nil, errors.New("epochs are not supported with snapshots")
+	}
+
+	resultRunner := result.NewEpochRunner(c.LogFlushInterval, c.Epochs, writer, resettable)
+
+	currentReport.AddStat(func() string {
+		return "Epoch: " + resultRunner.Epoch()
+	})
+
+	return resultRunner, nil
+}
+
+// newRepository creates the configured 32-bit repository with a func releasing its resources
+func newRepository(c Config, currentReport *report.Report) (repository.NumberRepository, func() error, error) {
+	switch c.Repository.Type {
+	case RepositoryMemory, "":
+		return repository.NewInMemoryRepository(), func() error { return nil }, nil
+
+	case RepositoryBloom:
+		numberRepository, err := repository.NewBloomRepository(c.Repository.BloomCapacity, c.Repository.BloomFalsePositiveRate, c.repositoryPath())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		currentReport.AddStat(func() string {
+			return fmt.Sprintf("Bloom false positive rate: %.4f%%", numberRepository.FalsePositiveRate()*100)
+		})
+
+		return numberRepository, numberRepository.Close, nil
+
+	case RepositoryTTL:
+		numberRepository, err := repository.NewTTLRepository(c.Repository.TTL, c.Repository.TTLBuckets)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return numberRepository, func() error { return nil }, nil
+
+	case RepositoryDisk:
+		numberRepository, err := repository.NewDiskRepository(c.repositoryPath())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return numberRepository, numberRepository.Close, nil
+
+	case RepositoryMmap:
+		numberRepository, err := repository.NewMmapRepository(c.repositoryPath(), uint32(c.LineFormat.MaxValue()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return numberRepository, numberRepository.Close, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown repository type: %s", c.Repository.Type)
+	}
+}
+
+// runExpiration expires numbers on each interval, counting them on the report
+func runExpiration(ctx context.Context, interval time.Duration, ttlRepository *repository.TTLRepository, currentReport *report.Report) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			currentReport.IncreaseExpired(uint(ttlRepository.Expire()))
+		}
+	}
+}
+
+// stop removes the logged numbers from the write-ahead log, saves the final snapshot and releases the repository,
+// to be called once all numbers are logged
+func (s *numberStore) stop(errHandle errhandler.ErrHandler) {
+	if s.wal != nil {
+		err := s.wal.Close()
+		if err != nil {
+			errHandle(errors.Wrap(err, "cannot close wal"))
+		}
+	}
+
+	if s.snapshotPath != "" {
+		err := snapshot.Save(s.snapshotPath, s.repository)
+		if err != nil {
+			errHandle(errors.Wrap(err, "cannot save final snapshot"))
+		}
+	}
+
+	err := s.close()
+	if err != nil {
+		errHandle(errors.Wrap(err, "cannot close repository"))
+	}
+}
+
+// release closes the write-ahead log, the result sink and the repository of a store whose runners never started,
+// keeping its numbers pending on the write-ahead log
+func (s *numberStore) release() error {
+	var err error
+	if s.wal != nil {
+		err = s.wal.Close()
+	}
+
+	sinkErr := s.sink.Close()
+	if err == nil {
+		err = sinkErr
+	}
+
+	closeErr := s.close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// TLSConfig enables tls on the listener when cert and key files are given
+// * ClientCAFile: if given, clients must present a certificate signed by this CA (mutual tls)
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// Enabled returns true if tls has been configured
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// load creates the crypto/tls config from the configured files, nil if tls is not enabled
+func (c TLSConfig) load() (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load tls certificate")
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile == "" {
+		return config, nil
+	}
+
+	caPEM, err := ioutil.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read tls client CA")
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, errors.Errorf("no certificates found on tls client CA %s", c.ClientCAFile)
+	}
+
+	config.ClientCAs = clientCAs
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return config, nil
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/errhandler"
+)
+
+func TestNumServer_RoundTripsNumbersOverTLS(t *testing.T) {
+	dir, certPEM, tlsConfig := writeSelfSignedCert(t)
+
+	_, port, logPath := runTLSServer(t, dir, tlsConfig, errhandler.Noop)
+
+	client, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), &tls.Config{RootCAs: certPool(t, certPEM)})
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+
+	_, err = client.Write([]byte(validMultiLineInput))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	assertLogEventuallyContains(t, logPath, "7007009\n", "314159265\n")
+}
+
+func TestNumServer_RejectsClientsWithoutCertOnMutualTLS(t *testing.T) {
+	dir, certPEM, tlsConfig := writeSelfSignedCert(t)
+
+	// self-signed cert acts as its own client CA
+	tlsConfig.ClientCAFile = tlsConfig.CertFile
+
+	_, port, _ := runTLSServer(t, dir, tlsConfig, errhandler.Noop)
+
+	client, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), &tls.Config{RootCAs: certPool(t, certPEM)})
+	if err == nil {
+		defer client.Close()
+		// on tls 1.3 client cert rejection is received after handshake
+		_, _ = client.Write([]byte(validOneLineInput))
+		_, err = client.Read(make([]byte, 1))
+	}
+
+	assert.Error(t, err, "client without certificate should be rejected")
+}
+
+func TestNumServer_ClosesClientsFailingTLSHandshake(t *testing.T) {
+	dir, certPEM, tlsConfig := writeSelfSignedCert(t)
+
+	tlsConfig.ClientCAFile = tlsConfig.CertFile
+
+	var errs int64
+	srv, port, _ := runTLSServer(t, dir, tlsConfig, func(err error) {
+		if err != nil {
+			atomic.AddInt64(&errs, 1)
+		}
+	})
+
+	clients := map[string]func() (net.Conn, error){
+		"without cert": func() (net.Conn, error) {
+			return tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), &tls.Config{RootCAs: certPool(t, certPEM)})
+		},
+		"plaintext": func() (net.Conn, error) {
+			return net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		},
+	}
+	for name, dial := range clients {
+		client, err := dial()
+		if err != nil {
+			continue
+		}
+		_, _ = client.Write([]byte(validOneLineInput))
+		// until closed by server, any alert is discarded
+		assert.NoError(t, client.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, err = io.Copy(ioutil.Discard, client)
+		assert.False(t, isTimeout(err), "%s client should be closed by server", name)
+		_ = client.Close()
+	}
+
+	close(srv.Stop)
+	select {
+	case <-srv.Stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server should stop, rejected clients should not be handled anymore")
+	}
+
+	logged := atomic.LoadInt64(&errs)
+	assert.True(t, logged <= int64(len(clients)), "rejected clients should be logged once, got %d errors", logged)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, logged, atomic.LoadInt64(&errs), "no errors should be logged once stopped")
+}
+
+func runTLSServer(t *testing.T, dir string, tlsConfig TLSConfig, errHandle errhandler.ErrHandler) (srv *NumServer, port int, logPath string) {
+	port = randPort()
+	logPath = filepath.Join(dir, DefaultLogFile)
+
+	config := NewConfig(port, logPath)
+	config.LogFlushInterval = 10 * time.Millisecond
+	config.TLS = tlsConfig
+
+	srv = NewNumServerWithConfig(*config)
+	srv.errHandle = errHandle
+
+	go srv.Run(context.Background())
+
+	select {
+	case <-srv.Ready:
+	case <-time.After(time.Second):
+		t.Fatal("tls server not ready")
+	}
+
+	return
+}
+
+func assertLogEventuallyContains(t *testing.T, logPath string, lines ...string) {
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		content, err := ioutil.ReadFile(logPath)
+		assert.NoError(t, err)
+
+		missing := 0
+		for _, l := range lines {
+			if !strings.Contains(string(content), l) {
+				missing++
+			}
+		}
+
+		if missing == 0 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("log %s does not contain %q: %q", logPath, lines, content)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// writeSelfSignedCert generates a self-signed cert for localhost, writing cert and key files on a temp dir
+func writeSelfSignedCert(t *testing.T) (dir string, certPEM []byte, config TLSConfig) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %s", err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "numserver"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %s", err.Error())
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("cannot marshal key: %s", err.Error())
+	}
+
+	dir = t.TempDir()
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	config = TLSConfig{
+		CertFile: filepath.Join(dir, "server.crt"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+	}
+	assert.NoError(t, ioutil.WriteFile(config.CertFile, certPEM, 0600))
+	assert.NoError(t, ioutil.WriteFile(config.KeyFile, keyPEM, 0600))
+
+	return
+}
+
+func certPool(t *testing.T, certPEM []byte) *x509.CertPool {
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(certPEM))
+
+	return pool
+}
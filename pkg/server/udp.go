@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/varas/numserver/pkg/line"
+	"github.com/varas/numserver/pkg/report"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+// max udp payload
+const maxDatagramSize = 65535
+
+// UDPListener reads datagrams of number lines, storing their numbers
+// invalid datagrams are dropped as a whole and counted on the report
+type UDPListener struct {
+	conn          net.PacketConn
+	access        *accessControl
+	lineValidator *line.Validator
+	numberSet     repository.NumberSet
+	report        *report.Report
+}
+
+// NewUDPListener creates a new datagram listener on given udp address
+func NewUDPListener(
+	address string,
+	access AccessPolicy,
+	lineValidator *line.Validator,
+	numberSet repository.NumberSet,
+	report *report.Report,
+) (*UDPListener, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot listen on socket udp/%s", address)
+	}
+
+	return &UDPListener{
+		conn:          conn,
+		access:        newAccessControl(access),
+		lineValidator: lineValidator,
+		numberSet:     numberSet,
+		report:        report,
+	}, nil
+}
+
+// Listen reads datagrams until stopped
+func (s *UDPListener) Listen(ctx context.Context) error {
+	go s.waitForContextTermination(ctx)
+
+	datagram := make([]byte, maxDatagramSize)
+
+	for {
+		n, addr, err := s.conn.ReadFrom(datagram)
+		if err != nil {
+			return err
+		}
+
+		// per ip limits do not apply to connectionless sources
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if ok && !s.access.isAllowed(udpAddr.IP) {
+			continue
+		}
+
+		s.handle(datagram[:n])
+	}
+}
+
+func (s *UDPListener) handle(datagram []byte) {
+	numbers, err := line.ParseNumberLines(datagram, s.lineValidator)
+	if err != nil {
+		s.report.IncreaseDropped()
+		return
+	}
+
+	for _, n := range numbers {
+		unique := s.numberSet.Add(n)
+		s.report.Increase(unique)
+	}
+}
+
+// Stop stops the listener
+func (s *UDPListener) Stop() {
+	_ = s.conn.Close()
+}
+
+// SetAccess changes the access policy applied from now on, already accepted clients are kept
+func (s *UDPListener) SetAccess(access AccessPolicy) {
+	s.access.setPolicy(access)
+}
+
+func (s *UDPListener) waitForContextTermination(ctx context.Context) {
+	<-ctx.Done()
+	s.Stop()
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/varas/numserver/pkg/errhandler"
+	"github.com/varas/numserver/pkg/line"
+	"github.com/varas/numserver/pkg/report"
+	"github.com/varas/numserver/pkg/repository"
+)
+
+func TestUDPListener_DropsInvalidDatagramsAsAWhole(t *testing.T) {
+	validator, err := line.NewValidator()
+	assert.NoError(t, err)
+
+	repo := repository.NewInMemoryRepository()
+	currentReport := &report.Report{}
+
+	l := &UDPListener{lineValidator: validator, numberSet: repository.NewNumberSet(repo), report: currentReport}
+
+	l.handle([]byte(validMultiLineInput))
+	l.handle([]byte("123456789\n" + invalidInput))
+	l.handle([]byte("314159265"))
+
+	assert.Len(t, repo.ExtractTransaction(), 2)
+	repo.Rollback()
+
+	assert.Equal(t,
+		"Received 2 unique numbers, 0 duplicates. Unique total: 2. Dropped 2 invalid datagrams\n",
+		currentReport.ReportTransaction(),
+	)
+	currentReport.Rollback()
+}
+
+func TestNumServer_ReadsUDPDatagrams(t *testing.T) {
+	dir := t.TempDir()
+
+	udpAddress := fmt.Sprintf("127.0.0.1:%d", randPort())
+	logPath := filepath.Join(dir, DefaultLogFile)
+
+	runServerWithConfig(errhandler.Noop, func(c *Config) {
+		c.UDPAddress = udpAddress
+		c.LogPath = logPath
+		c.LogFlushInterval = 10 * time.Millisecond
+	})
+
+	client, err := net.Dial("udp", udpAddress)
+	if err != nil {
+		t.Fatalf("cannot connect to server: %s", err.Error())
+	}
+	defer client.Close()
+
+	_, err = client.Write([]byte(validMultiLineInput))
+	assert.NoError(t, err)
+
+	assertLogEventuallyContains(t, logPath, "7007009\n", "314159265\n")
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// UnixSocketConfig unix domain socket settings, applied to every unix listen address
+// * Path: if given, a unix listener is added on this path besides the listen addresses
+// * Mode: socket file permissions, process umask applies if 0
+// * User, Group: socket file ownership as name or id, unchanged if empty
+type UnixSocketConfig struct {
+	Path  string
+	Mode  os.FileMode
+	User  string
+	Group string
+}
+
+// listenUnix listens on a unix socket path, removing it first if stale
+func listenUnix(path string, config UnixSocketConfig) (net.Listener, error) {
+	err := removeStaleSocket(path)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.apply(path)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// removeStaleSocket removes a socket file left by a non graceful stop, failing if it is still in use
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot stat socket %s", path)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.Errorf("cannot listen on %s: file exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return errors.Errorf("cannot listen on %s: socket already in use", path)
+	}
+
+	return errors.Wrapf(os.Remove(path), "cannot remove stale socket %s", path)
+}
+
+func (c UnixSocketConfig) apply(path string) error {
+	if c.Mode != 0 {
+		err := os.Chmod(path, c.Mode)
+		if err != nil {
+			return errors.Wrapf(err, "cannot set socket %s mode", path)
+		}
+	}
+
+	if c.User == "" && c.Group == "" {
+		return nil
+	}
+
+	uid, gid, err := c.owner()
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(os.Chown(path, uid, gid), "cannot set socket %s owner", path)
+}
+
+// owner resolves user and group to ids, -1 keeps them unchanged
+func (c UnixSocketConfig) owner() (uid, gid int, err error) {
+	uid, gid = -1, -1
+
+	if c.User != "" {
+		uid, err = strconv.Atoi(c.User)
+		if err != nil {
+			u, lookupErr := user.Lookup(c.User)
+			if lookupErr != nil {
+				return 0, 0, errors.Wrapf(lookupErr, "cannot find socket user %s", c.User)
+			}
+			uid, err = strconv.Atoi(u.Uid)
+			if err != nil {
+				return 0, 0, errors.Wrapf(err, "non numeric uid for socket user %s", c.User)
+			}
+		}
+	}
+
+	if c.Group != "" {
+		gid, err = strconv.Atoi(c.Group)
+		if err != nil {
+			g, lookupErr := user.LookupGroup(c.Group)
+			if lookupErr != nil {
+				return 0, 0, errors.Wrapf(lookupErr, "cannot find socket group %s", c.Group)
+			}
+			gid, err = strconv.Atoi(g.Gid)
+			if err != nil {
+				return 0, 0, errors.Wrapf(err, "non numeric gid for socket group %s", c.Group)
+			}
+		}
+	}
+
+	return uid, gid, nil
+}
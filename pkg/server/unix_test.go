@@ -0,0 +1,68 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenUnix_RemovesStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "numserver.sock")
+
+	// leave socket file behind as on a crash
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	assert.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	assert.NoError(t, stale.Close())
+
+	listener, err := listenUnix(path, UnixSocketConfig{})
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	conn.Close()
+}
+
+func TestListenUnix_FailsIfSocketInUse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "numserver.sock")
+
+	active, err := listenUnix(path, UnixSocketConfig{})
+	assert.NoError(t, err)
+	defer active.Close()
+
+	_, err = listenUnix(path, UnixSocketConfig{})
+	assert.Error(t, err)
+}
+
+func TestListenUnix_FailsIfPathIsNotSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "numbers.log")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("123\n"), 0600))
+
+	_, err := listenUnix(path, UnixSocketConfig{})
+	assert.Error(t, err)
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "123\n", string(content), "non socket files should be kept")
+}
+
+func TestListenUnix_AppliesMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "numserver.sock")
+
+	listener, err := listenUnix(path, UnixSocketConfig{Mode: 0600})
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
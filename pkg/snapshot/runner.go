@@ -0,0 +1,47 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/varas/numserver/pkg/errhandler"
+)
+
+// Runner saves a snapshot on each interval
+// * final snapshot is left to the caller, as it must be taken once state is no longer changing
+// * failed saves are handled and retried on next interval, the previous snapshot is kept meanwhile
+type Runner struct {
+	interval  time.Duration
+	path      string
+	state     Snapshotter
+	errHandle errhandler.ErrHandler
+}
+
+// NewRunner creates a snapshot runner daemon
+func NewRunner(interval time.Duration, path string, state Snapshotter, errHandle errhandler.ErrHandler) *Runner {
+	return &Runner{
+		interval:  interval,
+		path:      path,
+		state:     state,
+		errHandle: errHandle,
+	}
+}
+
+// Run runs saving snapshots on each interval
+func (r *Runner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			err := Save(r.path, r.state)
+			if err != nil {
+				r.errHandle(err)
+			}
+		}
+	}
+}
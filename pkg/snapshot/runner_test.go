@@ -0,0 +1,74 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyState fails its first snapshots
+type flakyState struct {
+	failures int
+	sync.Mutex
+}
+
+func (s *flakyState) Snapshot(w io.Writer) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("disk full")
+	}
+
+	_, err := w.Write([]byte("numbers"))
+
+	return err
+}
+
+func (s *flakyState) Restore(io.Reader) error {
+	return nil
+}
+
+func TestRunner_KeepsSavingAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "numbers.snapshot")
+
+	var handled []error
+	var handledLock sync.Mutex
+	runner := NewRunner(10*time.Millisecond, path, &flakyState{failures: 2}, func(err error) {
+		handledLock.Lock()
+		handled = append(handled, err)
+		handledLock.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runner.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	assert.FileExists(t, path, "snapshot should be saved once state recovers")
+	handledLock.Lock()
+	assert.Len(t, handled, 2)
+	handledLock.Unlock()
+}
@@ -0,0 +1,58 @@
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Snapshotter state which can be written as a snapshot and restored from it
+type Snapshotter interface {
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
+// Save writes a snapshot on given path, replacing the previous one only once the new one is durable
+func Save(path string, state Snapshotter) error {
+	tmp, err := os.Create(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp"))
+	if err != nil {
+		return errors.Wrap(err, "cannot create snapshot file")
+	}
+	defer os.Remove(tmp.Name())
+
+	err = state.Snapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	closeErr := tmp.Close()
+	if err != nil {
+		return errors.Wrap(err, "cannot write snapshot")
+	}
+	if closeErr != nil {
+		return errors.Wrap(closeErr, "cannot write snapshot")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), path), "cannot replace snapshot")
+}
+
+// Load restores the snapshot on given path, loaded is false if there is none
+func Load(path string, state Snapshotter) (loaded bool, err error) {
+	input, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "cannot open snapshot")
+	}
+	defer input.Close()
+
+	err = state.Restore(input)
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot restore snapshot %s", path)
+	}
+
+	return true, nil
+}
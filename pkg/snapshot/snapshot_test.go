@@ -0,0 +1,75 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeState snapshots its content
+type fakeState struct {
+	content  []byte
+	writeErr error
+}
+
+func (s *fakeState) Snapshot(w io.Writer) error {
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+
+	_, err := w.Write(s.content)
+
+	return err
+}
+
+func (s *fakeState) Restore(r io.Reader) (err error) {
+	s.content, err = ioutil.ReadAll(r)
+
+	return
+}
+
+func TestSave_WritesSnapshotLoadedBack(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "numbers.snapshot")
+
+	assert.NoError(t, Save(path, &fakeState{content: []byte("state")}))
+
+	restored := &fakeState{}
+	loaded, err := Load(path, restored)
+
+	assert.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, []byte("state"), restored.content)
+}
+
+func TestSave_KeepsPreviousSnapshotOnFailure(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "numbers.snapshot")
+
+	assert.NoError(t, Save(path, &fakeState{content: []byte("previous")}))
+	assert.Error(t, Save(path, &fakeState{writeErr: errors.New("write failed")}))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal([]byte("previous"), content))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1, "temporary snapshot should be removed")
+}
+
+func TestLoad_ReturnsNotLoadedIfMissing(t *testing.T) {
+	dir := t.TempDir()
+
+	loaded, err := Load(filepath.Join(dir, "missing.snapshot"), &fakeState{})
+
+	assert.NoError(t, err)
+	assert.False(t, loaded)
+}
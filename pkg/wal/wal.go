@@ -0,0 +1,330 @@
+package wal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// segment file name: generation, one per run, and the result runner flush including its numbers
+const segmentFormat = "%06d-%012d.wal"
+
+// Commits tracks the result runner flushes, as result.Commits does
+type Commits interface {
+	// Mark returns the flush that will include all numbers added so far
+	Mark() uint64
+	// Committed returns the last committed flush and a channel closed on next commit
+	Committed() (flush uint64, changed <-chan struct{})
+}
+
+// Log append-only write-ahead log of unique numbers accepted but not logged yet, so they survive a crash
+// * numbers are written on a segment per result runner flush, removed once the flush is committed
+// * appends are buffered, Sync makes them durable grouping concurrent callers on a single fsync
+// * a crash between a flush commit and its segment removal makes its numbers logged again on recovery
+type Log struct {
+	dir        string
+	generation int
+	commits    Commits
+	segments   []*segment
+	appended   uint64
+	synced     uint64
+	sync.Mutex
+	// held while syncing or removing segments, so concurrent syncs wait for the ongoing one
+	syncing sync.Mutex
+}
+
+type segment struct {
+	flush  uint64
+	fd     *os.File
+	output *bufio.Writer
+	// appended since last sync
+	dirty bool
+}
+
+// Open opens the write-ahead log on given dir, returning the numbers left by a previous run, which are kept
+// on the new log until logged
+func Open(dir string, commits Commits) (*Log, []uint64, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot create wal dir")
+	}
+
+	previous, generation, err := segmentFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recovered, err := readSegments(previous)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l := &Log{
+		dir:        dir,
+		generation: generation + 1,
+		commits:    commits,
+	}
+
+	for _, n := range recovered {
+		err = l.Append(n)
+		if err != nil {
+			_ = l.Close()
+			return nil, nil, err
+		}
+	}
+
+	err = l.Sync()
+	if err != nil {
+		_ = l.Close()
+		return nil, nil, err
+	}
+
+	// recovered numbers are durable on the new generation
+	for _, path := range previous {
+		err = os.Remove(path)
+		if err != nil {
+			_ = l.Close()
+			return nil, nil, errors.Wrap(err, "cannot remove recovered wal segment")
+		}
+	}
+
+	return l, recovered, nil
+}
+
+// Append appends a number, durable once synced
+func (l *Log) Append(number uint64) error {
+	l.Lock()
+	defer l.Unlock()
+
+	flush := l.commits.Mark()
+
+	last := len(l.segments) - 1
+	if last < 0 || l.segments[last].flush != flush {
+		s, err := l.createSegment(flush)
+		if err != nil {
+			return err
+		}
+		l.segments = append(l.segments, s)
+		last++
+	}
+
+	l.segments[last].dirty = true
+	output := l.segments[last].output
+	_, err := output.Write(strconv.AppendUint(nil, number, 10))
+	if err == nil {
+		err = output.WriteByte('\n')
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot write wal")
+	}
+
+	l.appended++
+
+	return nil
+}
+
+// Sync blocks until all numbers appended so far are durable
+func (l *Log) Sync() error {
+	l.Lock()
+	target := l.appended
+	done := l.synced >= target
+	l.Unlock()
+
+	if done {
+		return nil
+	}
+
+	l.syncing.Lock()
+	defer l.syncing.Unlock()
+
+	// synced meanwhile by a concurrent call
+	l.Lock()
+	if l.synced >= target {
+		l.Unlock()
+		return nil
+	}
+
+	appended := l.appended
+	var fds []*os.File
+	for _, s := range l.segments {
+		if !s.dirty {
+			continue
+		}
+
+		err := s.output.Flush()
+		if err != nil {
+			l.Unlock()
+			return errors.Wrap(err, "cannot write wal")
+		}
+		s.dirty = false
+		fds = append(fds, s.fd)
+	}
+	l.Unlock()
+
+	// segments are only closed while syncing is held
+	for _, fd := range fds {
+		err := fd.Sync()
+		if err != nil {
+			return errors.Wrap(err, "cannot sync wal")
+		}
+	}
+
+	l.Lock()
+	l.synced = appended
+	l.Unlock()
+
+	return nil
+}
+
+// Run removes segments as their flushes are committed until context is done
+func (l *Log) Run(ctx context.Context) error {
+	for {
+		committed, changed := l.commits.Committed()
+
+		err := l.checkpoint(committed)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+	}
+}
+
+// checkpoint removes the segments of committed flushes
+func (l *Log) checkpoint(committed uint64) error {
+	l.syncing.Lock()
+	defer l.syncing.Unlock()
+	l.Lock()
+	defer l.Unlock()
+
+	for len(l.segments) > 0 && l.segments[0].flush <= committed {
+		s := l.segments[0]
+
+		err := s.fd.Close()
+		if err != nil {
+			return errors.Wrap(err, "cannot close wal segment")
+		}
+
+		err = os.Remove(s.fd.Name())
+		if err != nil {
+			return errors.Wrap(err, "cannot remove wal segment")
+		}
+
+		l.segments = l.segments[1:]
+	}
+
+	return nil
+}
+
+// Close removes the segments already committed and syncs the rest, kept for recovery
+func (l *Log) Close() error {
+	committed, _ := l.commits.Committed()
+
+	err := l.checkpoint(committed)
+	if err != nil {
+		return err
+	}
+
+	err = l.Sync()
+
+	l.Lock()
+	defer l.Unlock()
+
+	for _, s := range l.segments {
+		closeErr := s.fd.Close()
+		if err == nil && closeErr != nil {
+			err = errors.Wrap(closeErr, "cannot close wal segment")
+		}
+	}
+	l.segments = nil
+
+	return err
+}
+
+func (l *Log) createSegment(flush uint64) (*segment, error) {
+	path := filepath.Join(l.dir, fmt.Sprintf(segmentFormat, l.generation, flush))
+
+	fd, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create wal segment")
+	}
+
+	return &segment{
+		flush:  flush,
+		fd:     fd,
+		output: bufio.NewWriter(fd),
+	}, nil
+}
+
+// segmentFiles returns the segment files on dir in write order and the last generation
+func segmentFiles(dir string) (paths []string, generation int, err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "cannot read wal dir")
+	}
+
+	// names sort in write order
+	var names []string
+	for _, f := range files {
+		var g int
+		var flush uint64
+		_, scanErr := fmt.Sscanf(f.Name(), segmentFormat, &g, &flush)
+		if scanErr != nil {
+			continue
+		}
+
+		names = append(names, f.Name())
+		if g > generation {
+			generation = g
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+
+	return paths, generation, nil
+}
+
+// readSegments reads the numbers of given segments once each, a partially written last line is skipped
+func readSegments(paths []string) (numbers []uint64, err error) {
+	seen := make(map[uint64]struct{})
+
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read wal segment")
+		}
+
+		lines := strings.Split(string(content), "\n")
+		// last one is either empty or not terminated
+		for _, line := range lines[:len(lines)-1] {
+			n, err := strconv.ParseUint(line, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "corrupted wal segment %s", path)
+			}
+
+			_, exists := seen[n]
+			if !exists {
+				seen[n] = struct{}{}
+				numbers = append(numbers, n)
+			}
+		}
+	}
+
+	return numbers, nil
+}
@@ -0,0 +1,187 @@
+package wal
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCommits flushes are started and committed by tests
+type fakeCommits struct {
+	started   uint64
+	committed uint64
+	changed   chan struct{}
+	sync.Mutex
+}
+
+func newFakeCommits() *fakeCommits {
+	return &fakeCommits{changed: make(chan struct{})}
+}
+
+func (c *fakeCommits) Mark() uint64 {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.started + 1
+}
+
+func (c *fakeCommits) Committed() (uint64, <-chan struct{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.committed, c.changed
+}
+
+// flush starts a flush and commits it
+func (c *fakeCommits) flush() {
+	c.Lock()
+	c.started++
+	c.committed = c.started
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.Unlock()
+}
+
+func TestLog_RecoversSyncedNumbers(t *testing.T) {
+	dir := t.TempDir()
+
+	l, recovered, err := Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+	assert.Len(t, recovered, 0)
+
+	for _, n := range []uint64{11, 22, 9999999999999999999} {
+		assert.NoError(t, l.Append(n))
+	}
+	assert.NoError(t, l.Sync())
+
+	// crash: not closed
+	_, recovered, err = Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{11, 22, 9999999999999999999}, recovered)
+}
+
+func TestLog_KeepsRecoveredNumbersUntilCommitted(t *testing.T) {
+	dir := t.TempDir()
+
+	l, _, err := Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+	assert.NoError(t, l.Append(11))
+	assert.NoError(t, l.Sync())
+
+	_, _, err = Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+
+	// crash again before recovered numbers are logged
+	_, again, err := Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{11}, again)
+
+	assertSegments(t, dir, 1)
+}
+
+func TestLog_RemovesCommittedSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	commits := newFakeCommits()
+	l, _, err := Open(dir, commits)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = l.Run(ctx)
+	}()
+
+	assert.NoError(t, l.Append(11))
+	commits.flush()
+	assert.NoError(t, l.Append(22))
+	assert.NoError(t, l.Sync())
+
+	assertEventuallySegments(t, dir, 1)
+
+	cancel()
+	assert.NoError(t, l.Close())
+
+	_, recovered, err := Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{22}, recovered, "committed numbers should not be recovered")
+}
+
+func TestLog_CloseRemovesCommittedSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	commits := newFakeCommits()
+	l, _, err := Open(dir, commits)
+	assert.NoError(t, err)
+
+	assert.NoError(t, l.Append(11))
+	commits.flush()
+
+	assert.NoError(t, l.Close())
+	assertSegments(t, dir, 0)
+}
+
+func TestLog_SkipsPartiallyWrittenLine(t *testing.T) {
+	dir := t.TempDir()
+
+	err := ioutil.WriteFile(filepath.Join(dir, "000001-000000000001.wal"), []byte("11\n22"), 0644)
+	assert.NoError(t, err)
+
+	_, recovered, err := Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{11}, recovered)
+}
+
+func TestLog_ConcurrentSyncs(t *testing.T) {
+	dir := t.TempDir()
+
+	l, _, err := Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for w := uint64(0); w < 10; w++ {
+		wg.Add(1)
+		go func(w uint64) {
+			defer wg.Done()
+			for i := uint64(0); i < 100; i++ {
+				assert.NoError(t, l.Append(w*1000+i))
+				assert.NoError(t, l.Sync())
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	_, recovered, err := Open(dir, newFakeCommits())
+	assert.NoError(t, err)
+	assert.Len(t, recovered, 1000)
+}
+
+func assertSegments(t *testing.T, dir string, amount int) {
+	paths, _, err := segmentFiles(dir)
+	assert.NoError(t, err)
+	assert.Len(t, paths, amount)
+}
+
+func assertEventuallySegments(t *testing.T, dir string, amount int) {
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		paths, _, err := segmentFiles(dir)
+		assert.NoError(t, err)
+
+		if len(paths) == amount {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("wal segments %q, expected %d", paths, amount)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}